@@ -2,13 +2,16 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/naluneotlichno/FP-GO-API/clock"
 	"github.com/naluneotlichno/FP-GO-API/database"
 	"github.com/naluneotlichno/FP-GO-API/nextdate"
 )
@@ -19,6 +22,11 @@ type AddTaskRequest struct {
 	Title   string `json:"title"`
 	Comment string `json:"comment"`
 	Repeat  string `json:"repeat"`
+	RRule   string `json:"rrule"` // альтернатива repeat в формате RFC 5545
+
+	Time     string  `json:"time"`     // время начала HH:MM, пустое — задача на весь день
+	Duration Minutes `json:"duration"` // длительность в минутах
+	TZ       string  `json:"tz"`       // часовой пояс IANA, пустой — пояс сервера
 }
 
 type AddTaskResponse struct {
@@ -30,18 +38,18 @@ type AddTaskResponse struct {
 const layout = "20060102"
 
 // AddTaskHandler обрабатывает POST-запросы на /api/task (аналог «КОД 1»).
-func AddTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) AddTaskHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("🚀 [AddTaskHandler] Начинаем обработку запроса")
 	switch r.Method {
 	case http.MethodPost:
-		AddTask(w, r)
+		s.AddTask(w, r)
 	default:
 		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
 	}
 
 }
 
-func AddTask(w http.ResponseWriter, r *http.Request) {
+func (s *Server) AddTask(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		log.Printf("Ошибка при чтении тела запроса: %v", err)
@@ -67,8 +75,29 @@ func AddTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rule, err := parseRepeat(req.Repeat, req.RRule)
+	if err != nil {
+		log.Printf("Неверное правило повторения: %v", err)
+		repeatError(w, req.RRule, err)
+		return
+	}
+	req.Repeat = rule.String()
+
+	if err := checkTimeOfDay(req.Time, req.Duration, rule); err != nil {
+		log.Printf("Неверное время задачи: %v", err)
+		JsonResponse(w, http.StatusBadRequest, AddTaskResponse{Error: err.Error()})
+		return
+	}
+
+	if _, err := clock.LoadZone(req.TZ); err != nil {
+		log.Printf("Неверный часовой пояс: %v", err)
+		JsonResponse(w, http.StatusBadRequest, AddTaskResponse{Error: "неизвестный часовой пояс"})
+		return
+	}
+
 	var taskDate time.Time
-	now := time.Now()
+	// «Сегодня» считается в часовом поясе задачи
+	now := clock.In(s.now(r), req.TZ)
 
 	if strings.TrimSpace(req.Date) == "" {
 		req.Date = now.Format(layout)
@@ -81,17 +110,36 @@ func AddTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if taskDate.Before(now) {
-		if strings.TrimSpace(req.Repeat) == "" {
-			taskDate = now
+	// Сравниваем календарные даты: задача на сегодня остаётся на сегодня в любое время суток.
+	// Правила h и min считаются по времени: прошедшее время начала переносится на ближайшее повторение.
+	start := nextdate.JoinDateTime(req.Date, req.Time)
+	overdue := req.Date < now.Format(layout) || rule.SubDaily() && start < now.Format(nextdate.DateTimeLayout)
+	remaining := 0
+	if overdue {
+		if rule.IsZero() {
+			taskDate, _ = time.Parse(layout, now.Format(layout))
 			log.Printf("Добавление задачи с текущей датой: %s", taskDate.Format(layout))
 		} else {
-			nextDateStr, err := nextdate.NextDate(now, req.Date, req.Repeat, "add")
+			nextDateStr, err := nextdate.NextDate(now, start, req.Repeat, "add")
+			if errors.Is(err, nextdate.ErrNoOccurrences) {
+				log.Printf("Повторения по правилу уже закончились: %v", err)
+				JsonResponse(w, http.StatusBadRequest, AddTaskResponse{Error: "повторения по правилу уже закончились"})
+				return
+			}
 			if err != nil {
 				log.Printf("Ошибка при расчёте следующей даты: %v", err)
 				JsonResponse(w, http.StatusBadRequest, AddTaskResponse{Error: "неверное правило повторения"})
 				return
 			}
+			// Повторения xN до новой даты уже прошли и в счёт не идут
+			if rule.Count > 0 {
+				if remaining = remainingFrom(rule, start, nextDateStr); remaining <= 0 {
+					log.Printf("Все %d повторений задачи уже прошли", rule.Count)
+					JsonResponse(w, http.StatusBadRequest, AddTaskResponse{Error: "повторения по правилу уже закончились"})
+					return
+				}
+			}
+			nextDateStr, req.Time = nextdate.SplitDateTime(nextDateStr)
 			taskDate, err = time.Parse(layout, nextDateStr)
 			if err != nil {
 				log.Printf("Ошибка при распарсивании следующей даты: %v", err)
@@ -105,15 +153,19 @@ func AddTask(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Добавление задачи с датой: %s", taskDate.Format(layout)) // Добавленное логирование
 
 	newTask := database.Task{
-		Date:    taskDate.Format(layout),
-		Title:   req.Title,
-		Comment: req.Comment,
-		Repeat:  req.Repeat,
+		Date:      taskDate.Format(layout),
+		Title:     req.Title,
+		Comment:   req.Comment,
+		Repeat:    req.Repeat,
+		Remaining: remaining,
+		Time:      req.Time,
+		Duration:  int(req.Duration),
+		TZ:        req.TZ,
 	}
 
 	log.Printf("Сохранение задачи в базе данных: %+v", newTask) // Добавленное логирование
 
-	id, err := database.AddTask(newTask)
+	id, err := s.store.AddTask(newTask)
 	if err != nil {
 		log.Printf("Ошибка при сохранении задачи в базе данных: %v", err)
 		JsonResponse(w, http.StatusInternalServerError, AddTaskResponse{Error: err.Error()})
@@ -124,24 +176,152 @@ func AddTask(w http.ResponseWriter, r *http.Request) {
 	JsonResponse(w, http.StatusCreated, AddTaskResponse{ID: fmt.Sprintf("%d", id)})
 }
 
+// remainingFrom возвращает, сколько из rule.Count повторений задачи, начинающейся в start,
+// приходится на next и позже. start и next — в формате nextdate.JoinDateTime, оба со временем или оба без.
+func remainingFrom(rule nextdate.RepeatRule, start, next string) int {
+	dateLayout := layout
+	if len(start) > len(dateLayout) {
+		dateLayout = nextdate.DateTimeLayout
+	}
+	first, err := time.Parse(dateLayout, start)
+	if err != nil {
+		return rule.Count
+	}
+
+	// Даты-исключения тоже засчитываются в Count, поэтому перебираются все повторения
+	rule.Except = nil
+	passed := 0
+	for d := range nextdate.Occurrences(first, rule, first.Add(-time.Nanosecond), 0) {
+		if d.Format(dateLayout) >= next {
+			break
+		}
+		passed++
+	}
+	return rule.Count - passed
+}
+
+// parseRepeat разбирает правило повторения, заданное либо в нашем формате (repeat),
+// либо строкой RRULE (rrule). Указывать оба сразу нельзя.
+func parseRepeat(repeat, rrule string) (nextdate.RepeatRule, error) {
+	if strings.TrimSpace(rrule) == "" {
+		return nextdate.Parse(repeat)
+	}
+	if strings.TrimSpace(repeat) != "" {
+		return nextdate.RepeatRule{}, &nextdate.RuleError{
+			Code:   nextdate.CodeConflict,
+			Pos:    -1,
+			Detail: "нужно указать только одно из полей repeat и rrule",
+		}
+	}
+	return nextdate.ParseRRule(rrule)
+}
+
+// repeatError отвечает 400 с разобранной ошибкой правила повторения:
+// {"error": {"code": ..., "field": "repeat", "detail": ...}}.
+func repeatError(w http.ResponseWriter, rrule string, err error) {
+	field := "repeat"
+	if strings.TrimSpace(rrule) != "" {
+		field = "rrule"
+	}
+	JsonResponse(w, http.StatusBadRequest, map[string]any{"error": nextdate.FieldErrorOf(field, err)})
+}
+
+// maxDuration — наибольшая длительность задачи в минутах: сутки.
+const maxDuration = 24 * 60
+
+// Minutes — длительность в минутах. В JSON принимается и числом, и строкой,
+// а отдаётся строкой, как и остальные поля задачи.
+type Minutes int
+
+// UnmarshalJSON разбирает 15, "15" и пустую строку.
+func (m *Minutes) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*m = 0
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("длительность должна быть числом минут: %s", data)
+	}
+	*m = Minutes(n)
+	return nil
+}
+
+// MarshalJSON отдаёт длительность строкой.
+func (m Minutes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.Itoa(int(m)))
+}
+
+// checkTimeOfDay проверяет время начала HH:MM и длительность задачи.
+// Длительность без времени начала не имеет смысла и не допускается,
+// как и правила h и min: они отсчитываются от времени начала.
+func checkTimeOfDay(start string, duration Minutes, rule nextdate.RepeatRule) error {
+	if start != "" {
+		if _, err := time.Parse("15:04", start); err != nil || len(start) != len("15:04") {
+			return errors.New("время указано в неверном формате, ожидается HH:MM")
+		}
+	}
+	if duration < 0 || duration > maxDuration {
+		return fmt.Errorf("длительность должна быть от 0 до %d минут", maxDuration)
+	}
+	if duration > 0 && start == "" {
+		return errors.New("длительность можно указать только вместе со временем начала")
+	}
+	if rule.SubDaily() && start == "" {
+		return errors.New("для правил h и min нужно указать время начала")
+	}
+	return nil
+}
+
+// describeRepeat описывает правило словами; для некорректного правила возвращает пустую строку.
+func describeRepeat(repeat, locale string) string {
+	rule, err := nextdate.Parse(repeat)
+	if err != nil {
+		return ""
+	}
+	return nextdate.Describe(rule, locale)
+}
+
 type TaskResponseItem struct {
-	ID      string `json:"id"`
-	Date    string `json:"date"`
-	Title   string `json:"title"`
-	Comment string `json:"comment"`
-	Repeat  string `json:"repeat"`
+	ID          string  `json:"id"`
+	Date        string  `json:"date"`
+	Title       string  `json:"title"`
+	Comment     string  `json:"comment"`
+	Repeat      string  `json:"repeat"`
+	Description string  `json:"description"` // правило повторения словами
+	Time        string  `json:"time"`
+	Duration    Minutes `json:"duration"`
+	TZ          string  `json:"tz"`
+	ExDates     string  `json:"exdates"` // даты-исключения через запятую
 }
 
 type TasksR struct {
 	List []TaskResponseItem `json:"list"`
+	// NextCursor — значение cursor для следующей страницы; на последней странице его нет
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
-func Tasks(w http.ResponseWriter, r *http.Request) {
-	tasks, err := database.GetUpcomingTasks()
+// Tasks обрабатывает GET /api/tasks: предстоящие задачи страницами по limit задач;
+// следующая страница запрашивается с cursor из next_cursor предыдущей.
+// Запрос с параметром search — это поиск, его выполняет GetTasksHandler.
+func (s *Server) Tasks(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("search") != "" {
+		s.GetTasksHandler(w, r)
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		JsonResponse(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	tasks, err := s.store.UpcomingTasks(s.now(r), p.after, p.limit+1)
 	if err != nil {
 		log.Printf("Ошибка получения задач: %v", err)
 		JsonResponse(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
@@ -149,17 +329,28 @@ func Tasks(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := TasksR{List: []TaskResponseItem{}}
+	tasks, response.NextCursor = p.next(tasks)
+	locale := nextdate.LocaleFromRequest(r)
 
 	for _, t := range tasks {
-		taskItem := TaskResponseItem{
-			ID:      fmt.Sprintf("%d", t.ID),
-			Date:    t.Date,
-			Title:   t.Title,
-			Comment: t.Comment,
-			Repeat:  t.Repeat,
-		}
-		response.List = append(response.List, taskItem)
+		response.List = append(response.List, taskResponseItem(t, locale))
 	}
 
 	JsonResponse(w, http.StatusOK, response)
 }
+
+// taskResponseItem переводит задачу из базы в элемент списка
+func taskResponseItem(t database.Task, locale string) TaskResponseItem {
+	return TaskResponseItem{
+		ID:          fmt.Sprintf("%d", t.ID),
+		Date:        t.Date,
+		Title:       t.Title,
+		Comment:     t.Comment,
+		Repeat:      t.Repeat,
+		Description: describeRepeat(t.Repeat, locale),
+		Time:        t.Time,
+		Duration:    Minutes(t.Duration),
+		TZ:          t.TZ,
+		ExDates:     strings.Join(t.ExDates, ","),
+	}
+}
@@ -3,18 +3,16 @@ package api
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/naluneotlichno/FP-GO-API/database"
-	"github.com/naluneotlichno/FP-GO-API/nextdate"
 )
 
 // DoneTaskHandler обрабатывает POST /api/task/done?id=...
-func DoneTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) DoneTaskHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("🔥 [DoneTaskHandler] Запрос на /api/task/done получен...")
 
 	idStr := r.URL.Query().Get("id")
@@ -32,41 +30,78 @@ func DoneTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task, err := database.GetTaskByID(id)
+	version, ok := ifMatch(r)
+	if !ok {
+		preconditionFailed(w)
+		return
+	}
+
+	now := s.now(r)
+	undo, err := s.newUndo(now)
 	if err != nil {
+		log.Printf("🚨 [DoneTaskHandler] Ошибка создания токена отмены: %v\n", err)
+		JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка при обновлении задачи"})
+		return
+	}
+
+	// Чтение задачи, расчёт следующей даты и запись выполняются в одной транзакции
+	if _, err := s.store.DoneTask(id, now, undo, version); err != nil {
 		if errors.Is(err, database.ErrTask) {
 			JsonResponse(w, http.StatusNotFound, map[string]string{"error": "Задача не найдена"})
 			return
 		}
-		log.Printf("🚨 [DoneTaskHandler] Ошибка получения задачи ID=%d: %v\n", id, err)
-		JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка при получении задачи"})
+		if errors.Is(err, database.ErrVersion) {
+			preconditionFailed(w)
+			return
+		}
+		log.Printf("🚨 [DoneTaskHandler] Ошибка при сохранении выполнения задачи ID=%d: %v\n", id, err)
+		JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка при обновлении задачи"})
 		return
 	}
+	setUndoHeader(w, undo)
 
-	log.Printf("✅ [DoneTaskHandler] Найдена задача: %#v\n", task)
+	JsonResponse(w, http.StatusOK, map[string]any{})
+}
 
-	if task.Repeat == "" {
-		log.Printf("🔍 [DoneTaskHandler] repeat пустой. Удаляем задачу ID=%d\n", id)
-		if err := database.DeleteTask(id); err != nil {
-			log.Printf("🚨 [DoneTaskHandler] Ошибка при удалении задачи ID=%d: %v\n", id, err)
-			JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка при удалении задачи"})
-			return
-		}
-	} else {
-		now := time.Now()
-		nextDate, err := nextdate.NextDate(now, task.Date, task.Repeat, "done")
-		if err != nil {
-			log.Printf("🚨 [DoneTaskHandler] Ошибка вычисления следующей даты: %v\n", err)
-			JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка при вычислении следующей даты"})
-			return
-		}
+// SkipTaskHandler обрабатывает POST /api/task/skip?id=...&date=...
+// Дата добавляется в исключения повторяющейся задачи. Если пропускается
+// ближайшее повторение, задача сразу переносится на следующее, как при выполнении.
+func (s *Server) SkipTaskHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("🔥 [SkipTaskHandler] Запрос на /api/task/skip получен...")
 
-		task.Date = nextDate
-		err = database.UpdateTask(task)
-		if err != nil {
-			JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка при обновлении задачи"})
-			return
-		}
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		log.Println("🚨 [SkipTaskHandler] ID не указан")
+		JsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Не указан идентификатор"})
+		return
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		log.Printf("🚨 [SkipTaskHandler] Ошибка парсинга ID=%s: %v\n", idStr, err)
+		JsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Некорректный идентификатор"})
+		return
+	}
+
+	date := r.URL.Query().Get("date")
+	if _, err := time.Parse(layout, date); err != nil {
+		log.Printf("🚨 [SkipTaskHandler] Некорректная дата %q: %v\n", date, err)
+		JsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Некорректная дата"})
+		return
+	}
+
+	err = s.store.SkipTask(id, date, s.now(r))
+	switch {
+	case errors.Is(err, database.ErrTask):
+		JsonResponse(w, http.StatusNotFound, map[string]string{"error": "Задача не найдена"})
+		return
+	case errors.Is(err, database.ErrNotRecurring):
+		JsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Пропустить можно только повторение повторяющейся задачи"})
+		return
+	case err != nil:
+		log.Printf("🚨 [SkipTaskHandler] Ошибка сохранения задачи ID=%d: %v\n", id, err)
+		JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка при обновлении задачи"})
+		return
 	}
 
 	JsonResponse(w, http.StatusOK, map[string]any{})
@@ -82,7 +117,7 @@ func JsonResponse(w http.ResponseWriter, status int, payload interface{}) {
 }
 
 // DeleteTaskHandler обрабатывает DELETE /api/task?id=...
-func DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("🔥 [DeleteTaskHandler] Запрос на DELETE /api/task получен...")
 
 	idStr := r.URL.Query().Get("id")
@@ -100,17 +135,37 @@ func DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, ok := ifMatch(r)
+	if !ok {
+		preconditionFailed(w)
+		return
+	}
+
+	now := s.now(r)
+	undo, err := s.newUndo(now)
+	if err != nil {
+		log.Printf("🚨 [DeleteTaskHandler] Ошибка создания токена отмены: %v\n", err)
+		JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка при удалении задачи"})
+		return
+	}
+
+	// Задача уходит в корзину, откуда её можно восстановить, пока корзину не очистили
 	log.Printf("🔍 [DeleteTaskHandler] Пытаемся удалить задачу с ID=%d\n", id)
-	if err := database.DeleteTask(id); err != nil {
-		if errors.Is(err, fmt.Errorf("задача не найдена")) {
-			JsonResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	if err := s.store.TrashTask(id, now, undo, version); err != nil {
+		if errors.Is(err, database.ErrVersion) {
+			preconditionFailed(w)
+			return
+		}
+		if errors.Is(err, database.ErrTask) {
+			JsonResponse(w, http.StatusNotFound, map[string]string{"error": "Задача не найдена"})
 			return
 		}
 		log.Printf("🚨 [DeleteTaskHandler] Ошибка удаления задачи ID=%d: %v\n", id, err)
-		JsonResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка при удалении задачи"})
 		return
 	}
 
 	log.Printf("✅ [DeleteTaskHandler] Задача ID=%d успешно удалена\n", id)
+	setUndoHeader(w, undo)
 	JsonResponse(w, http.StatusOK, map[string]interface{}{})
 }
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag возвращает ETag задачи с версией version
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatch разбирает заголовок If-Match и возвращает ожидаемую версию задачи:
+// 0 — заголовка нет или он равен «*», то есть подойдёт любая версия.
+// ok == false, если заголовок не указывает ровно на одну версию нашего формата:
+// такое условие не может выполниться, и запрос отклоняется с 412.
+func ifMatch(r *http.Request) (version int64, ok bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	// Слабые ETag (W/"...") при If-Match не сравниваются, как и требует RFC 9110
+	unquoted, found := strings.CutPrefix(header, `"`)
+	if !found {
+		return 0, false
+	}
+	unquoted, found = strings.CutSuffix(unquoted, `"`)
+	if !found {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// preconditionFailed отвечает 412: задачу успели изменить после того, как клиент её прочитал
+func preconditionFailed(w http.ResponseWriter) {
+	JsonResponse(w, http.StatusPreconditionFailed, map[string]string{"error": "Задача была изменена, обновите её и повторите"})
+}
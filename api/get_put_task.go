@@ -1,123 +1,20 @@
 package api
 
 import (
-	"database/sql"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/naluneotlichno/FP-GO-API/clock"
 	"github.com/naluneotlichno/FP-GO-API/database" // Предполагаем, что тут лежит твоя логика DB
+	"github.com/naluneotlichno/FP-GO-API/nextdate"
 )
 
-// Task описывает поля задачи
-type Task struct {
-	ID      string `json:"id"`      // Идентификатор задачи в формате строки
-	Date    string `json:"date"`    // Дата задачи в формате YYYYMMDD
-	Title   string `json:"title"`   // Заголовок задачи
-	Comment string `json:"comment"` // Комментарий
-	Repeat  string `json:"repeat"`  // Параметры повторения задачи, например "d 5"
-}
-
-// GetTaskByID получает задачу из таблицы scheduler по ID.
-// Возвращает ошибку, если не найдена.
-func GetTaskByID(id string) (Task, error) {
-
-	
-	db, _ := database.GetDB()
-
-	idInt, err := strconv.ParseInt(id, 10, 64)
-	if err != nil {
-		// Если ID не число, возвращаем ту же ошибку, что и "не найдена"
-		return Task{}, errors.New("task not found")
-	}
-
-	var t Task
-	row := db.QueryRow(`SELECT id, date, title, comment, repeat FROM scheduler WHERE id=?`, idInt)
-	err = row.Scan(&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return Task{}, errors.New("task not found")
-		}
-		return Task{}, err
-	}
-
-	return t, nil
-}
-
-// UpdateTask обновляет запись о задаче в таблице scheduler.
-// Возвращает ошибку, если задача не найдена или данные невалидны.
-func UpdateTask(task Task) error {
-	db, _ := database.GetDB()
-
-	// Проверяем, что ID — целое число
-	idInt, err := strconv.ParseInt(task.ID, 10, 64)
-	if err != nil {
-		return errors.New("task not found")
-	}
-
-	// Проверяем формат даты: YYYYMMDD
-	parsedDate, err := time.Parse("20060102", task.Date)
-	if err != nil {
-		return fmt.Errorf("invalid date format") // Перехватим это выше и вернём JSON "error"
-	}
-	// Дата не должна быть в прошлом
-	now := time.Now().Truncate(24 * time.Hour)
-	if parsedDate.Before(now) {
-		return fmt.Errorf("date is in the past")
-	}
-
-	// Проверяем, что title не пустой
-	if strings.TrimSpace(task.Title) == "" {
-		return fmt.Errorf("title is empty")
-	}
-
-	// Валидируем repeat. Тест явно ожидает ошибку, если repeat = "ooops".
-	// Допустим, разрешаем пустую строку, или формат "d 5", "d 7", "m 1" и т.д.
-	if task.Repeat != "" {
-		matched, _ := regexp.MatchString(`^[dmw]\s+\d+$`, task.Repeat)
-		if !matched {
-			return fmt.Errorf("invalid repeat format")
-		}
-	}
-
-	// Пытаемся обновить задачу в БД
-	res, err := db.Exec(`
-        UPDATE scheduler
-           SET date    = ?,
-               title   = ?,
-               comment = ?,
-               repeat  = ?
-         WHERE id = ?;
-    `,
-		task.Date,
-		task.Title,
-		task.Comment,
-		task.Repeat,
-		idInt,
-	)
-	if err != nil {
-		return err
-	}
-
-	// Смотрим, затронута ли хотя бы одна строка
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rowsAffected == 0 {
-		return errors.New("task not found")
-	}
-
-	return nil
-}
-
 // GetTaskHandler обрабатывает GET /api/task?id=<ID>
-func GetTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetTaskHandler(w http.ResponseWriter, r *http.Request) {
 	idStr := r.URL.Query().Get("id")
 	if idStr == "" {
 		JsonResponse(w, http.StatusBadRequest, map[string]string{"error": "отсутствует id"})
@@ -130,36 +27,59 @@ func GetTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	foundTask, err := database.GetTaskByID(id)
+	foundTask, err := s.store.GetTaskByID(id)
 	if err != nil {
 		JsonResponse(w, http.StatusNotFound, map[string]string{"error": "задача не найдена"})
 		return
 	}
 
 	response := map[string]string{
-		"id":      strconv.FormatInt(foundTask.ID, 10),
-		"date":    foundTask.Date,
-		"title":   foundTask.Title,
-		"comment": foundTask.Comment,
-		"repeat":  foundTask.Repeat,
+		"id":       strconv.FormatInt(foundTask.ID, 10),
+		"date":     foundTask.Date,
+		"title":    foundTask.Title,
+		"comment":  foundTask.Comment,
+		"repeat":   foundTask.Repeat,
+		"time":     foundTask.Time,
+		"duration": strconv.Itoa(foundTask.Duration),
+		"tz":       foundTask.TZ,
+		"exdates":  strings.Join(foundTask.ExDates, ","),
+	}
+
+	// Правило дополнительно отдаём словами и в формате RRULE, если оно в нём выражается
+	if rule, err := nextdate.Parse(foundTask.Repeat); err == nil {
+		response["description"] = nextdate.Describe(rule, nextdate.LocaleFromRequest(r))
+		if rrule, err := rule.RRule(); err == nil && rrule != "" {
+			response["rrule"] = rrule
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(foundTask.Version))
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
 
-var task struct {
+// updateAttempts — сколько раз PUT без If-Match перечитывает задачу, которую
+// изменили одновременно с ним, прежде чем ответить 412
+const updateAttempts = 3
+
+// UpdateTaskRequest — тело запроса PUT /api/task
+type UpdateTaskRequest struct {
 	ID      string `json:"id"`
 	Date    string `json:"date"`
 	Title   string `json:"title"`
 	Comment string `json:"comment"`
 	Repeat  string `json:"repeat"`
-}
+	RRule   string `json:"rrule"` // альтернатива repeat в формате RFC 5545
 
+	Time     string  `json:"time"`
+	Duration Minutes `json:"duration"`
+	TZ       string  `json:"tz"`
+}
 
-func UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
 
+	var task UpdateTaskRequest
 	decoder := json.NewDecoder(r.Body)
 	err := decoder.Decode(&task)
 
@@ -195,25 +115,82 @@ func UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updatedTask := database.Task{
-		ID:      id,
-		Date:    task.Date,
-		Title:   task.Title,
-		Comment: task.Comment,
-		Repeat:  task.Repeat,
+	rule, err := parseRepeat(task.Repeat, task.RRule)
+	if err != nil {
+		repeatError(w, task.RRule, err)
+		return
 	}
 
-	taskErr := database.UpdateTask(updatedTask)
-	if taskErr != nil {
-		if errors.Is(taskErr, database.ErrTask) {
-			JsonResponse(w, http.StatusNotFound, map[string]string{"error": "Задача не найдена"})
-		} else {
-			JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка обновления задачи"})
-		}
+	if err := checkTimeOfDay(task.Time, task.Duration, rule); err != nil {
+		JsonResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if _, err := clock.LoadZone(task.TZ); err != nil {
+		JsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Неизвестный часовой пояс"})
 		return
 	}
 
+	version, ok := ifMatch(r)
+	if !ok {
+		preconditionFailed(w)
+		return
+	}
+
+	// Остаток повторений и исключения ведёт сервер: они берутся из прочитанной задачи,
+	// и запись проходит, только пока её версия не изменилась. Если задачу тем временем
+	// выполнили или пропустили, запрос без If-Match перечитывает её и пробует снова.
+	for attempt := 1; ; attempt++ {
+		stored, err := s.store.GetTaskByID(id)
+		if err != nil {
+			if errors.Is(err, database.ErrTask) {
+				JsonResponse(w, http.StatusNotFound, map[string]string{"error": "Задача не найдена"})
+			} else {
+				JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка обновления задачи"})
+			}
+			return
+		}
+		// С If-Match задача сохранится, только если её не изменили с момента чтения клиентом
+		if version != 0 && stored.Version != version {
+			preconditionFailed(w)
+			return
+		}
+
+		updatedTask := database.Task{
+			ID:        id,
+			Date:      task.Date,
+			Title:     task.Title,
+			Comment:   task.Comment,
+			Repeat:    rule.String(),
+			Remaining: stored.Remaining,
+			Time:      task.Time,
+			Duration:  int(task.Duration),
+			TZ:        task.TZ,
+			ExDates:   stored.ExDates,
+			Version:   stored.Version,
+		}
+		// При смене правила счётчик повторений начинается заново
+		if updatedTask.Repeat != stored.Repeat {
+			updatedTask.Remaining = rule.Count
+		}
+
+		taskErr := s.store.UpdateTask(updatedTask)
+		if errors.Is(taskErr, database.ErrVersion) && version == 0 && attempt < updateAttempts {
+			continue
+		}
+		if taskErr != nil {
+			if errors.Is(taskErr, database.ErrTask) {
+				JsonResponse(w, http.StatusNotFound, map[string]string{"error": "Задача не найдена"})
+			} else if errors.Is(taskErr, database.ErrVersion) {
+				preconditionFailed(w)
+			} else {
+				JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка обновления задачи"})
+			}
+			return
+		}
+		break
+	}
+
 	JsonResponse(w, http.StatusOK, map[string]interface{}{})
 
 }
-
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/naluneotlichno/FP-GO-API/database"
+)
+
+// CompletionItem — запись истории выполнения; все поля строковые, как и у задач
+type CompletionItem struct {
+	ID     string `json:"id"`
+	TaskID string `json:"task_id"`
+	Title  string `json:"title"`
+	Date   string `json:"date"`
+	Time   string `json:"time"`
+	DoneAt string `json:"done_at"`
+}
+
+// CompletionsResponse — ответ /api/task/history и /api/completions
+type CompletionsResponse struct {
+	Completions []CompletionItem `json:"completions"`
+}
+
+func completionsResponse(list []database.Completion) CompletionsResponse {
+	response := CompletionsResponse{Completions: []CompletionItem{}}
+	for _, c := range list {
+		response.Completions = append(response.Completions, CompletionItem{
+			ID:     strconv.FormatInt(c.ID, 10),
+			TaskID: strconv.FormatInt(c.TaskID, 10),
+			Title:  c.Title,
+			Date:   c.Date,
+			Time:   c.Time,
+			DoneAt: c.DoneAt,
+		})
+	}
+	return response
+}
+
+// TaskHistoryHandler обрабатывает GET /api/task/history?id=...
+// История остаётся и после того, как задача выполнена окончательно и удалена.
+func (s *Server) TaskHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("🔥 [TaskHistoryHandler] Запрос на /api/task/history получен...")
+
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		JsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Не указан идентификатор"})
+		return
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		JsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Некорректный идентификатор"})
+		return
+	}
+
+	history, err := s.store.GetTaskHistory(id)
+	if err != nil {
+		log.Printf("🚨 [TaskHistoryHandler] Ошибка чтения истории задачи ID=%d: %v\n", id, err)
+		JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка чтения истории"})
+		return
+	}
+
+	// Пустая история у несуществующей задачи — это неизвестный идентификатор
+	if len(history) == 0 {
+		if _, err := s.store.GetTaskByID(id); errors.Is(err, database.ErrTask) {
+			JsonResponse(w, http.StatusNotFound, map[string]string{"error": "Задача не найдена"})
+			return
+		}
+	}
+
+	JsonResponse(w, http.StatusOK, completionsResponse(history))
+}
+
+// CompletionsHandler обрабатывает GET /api/completions?from=YYYYMMDD&to=YYYYMMDD:
+// всё, что было выполнено в эти дни включительно. Любую из границ можно не указывать.
+func (s *Server) CompletionsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("🔥 [CompletionsHandler] Запрос на /api/completions получен...")
+
+	from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	for _, p := range [][2]string{{"from", from}, {"to", to}} {
+		if p[1] == "" {
+			continue
+		}
+		if _, err := time.Parse(layout, p[1]); err != nil {
+			JsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Некорректная дата " + p[0]})
+			return
+		}
+	}
+	if from != "" && to != "" && to < from {
+		JsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Дата to раньше даты from"})
+		return
+	}
+
+	completions, err := s.store.GetCompletions(from, to)
+	if err != nil {
+		log.Printf("🚨 [CompletionsHandler] Ошибка чтения истории: %v\n", err)
+		JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка чтения истории"})
+		return
+	}
+
+	JsonResponse(w, http.StatusOK, completionsResponse(completions))
+}
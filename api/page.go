@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/naluneotlichno/FP-GO-API/database"
+	"github.com/naluneotlichno/FP-GO-API/nextdate"
+)
+
+// tasksLimit — сколько задач отдают списки задач, если limit не указан
+const tasksLimit = 50
+
+// maxTasksLimit — больше этого числа задач за один запрос не отдаётся
+const maxTasksLimit = 500
+
+// page — страница списка задач: с какого места продолжать и сколько задач отдать
+type page struct {
+	after database.Cursor
+	limit int
+}
+
+// parsePage читает параметры limit и cursor запроса списка задач
+func parsePage(r *http.Request) (page, error) {
+	p := page{limit: tasksLimit}
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxTasksLimit {
+			return page{}, fmt.Errorf("Некорректный limit: ожидается число от 1 до %d", maxTasksLimit)
+		}
+		p.limit = n
+	}
+	if s := r.URL.Query().Get("cursor"); s != "" {
+		after, err := decodeCursor(s)
+		if err != nil {
+			return page{}, errors.New("Некорректный cursor")
+		}
+		p.after = after
+	}
+	return p, nil
+}
+
+// next обрезает выборку, запрошенную с лимитом p.limit+1, до p.limit задач
+// и возвращает курсор следующей страницы; на последней странице он пустой
+func (p page) next(tasks []database.Task) ([]database.Task, string) {
+	if len(tasks) <= p.limit {
+		return tasks, ""
+	}
+	tasks = tasks[:p.limit]
+	return tasks, encodeCursor(database.CursorOf(tasks[len(tasks)-1]))
+}
+
+// encodeCursor и decodeCursor переводят курсор в непрозрачную для клиента строку и обратно
+func encodeCursor(c database.Cursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.Date + "," + strconv.FormatInt(c.ID, 10)))
+}
+
+func decodeCursor(s string) (database.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return database.Cursor{}, err
+	}
+	date, idStr, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return database.Cursor{}, errors.New("нет ID задачи")
+	}
+
+	layout := "20060102"
+	if len(date) > len(layout) {
+		layout = nextdate.DateTimeLayout
+	}
+	if _, err := time.Parse(layout, date); err != nil {
+		return database.Cursor{}, err
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id < 1 {
+		return database.Cursor{}, errors.New("некорректный ID задачи")
+	}
+	return database.Cursor{Date: date, ID: id}, nil
+}
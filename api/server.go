@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/naluneotlichno/FP-GO-API/clock"
+	"github.com/naluneotlichno/FP-GO-API/database"
+)
+
+// DefaultUndoWindow — сколько по умолчанию можно отменить выполнение или удаление задачи.
+const DefaultUndoWindow = time.Minute
+
+// Server объединяет обработчики API задач и их зависимости.
+type Server struct {
+	clock clock.Clock
+	store database.TaskStore
+
+	// UndoWindow — сколько действует токен отмены; 0 — отмена выключена
+	UndoWindow time.Duration
+}
+
+// NewServer создаёт сервер API, который берёт текущее время из c, а задачи хранит в store.
+func NewServer(c clock.Clock, store database.TaskStore) *Server {
+	return &Server{clock: c, store: store, UndoWindow: DefaultUndoWindow}
+}
+
+// now возвращает текущее время для обработки запроса r.
+func (s *Server) now(r *http.Request) time.Time {
+	return clock.FromRequest(s.clock, r)
+}
@@ -0,0 +1,790 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/naluneotlichno/FP-GO-API/clock"
+	"github.com/naluneotlichno/FP-GO-API/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer поднимает сервер на чистой базе во временном каталоге.
+// Часы сервера показывают *now, так что тест может их переводить.
+func newTestServer(t *testing.T, now *time.Time) *Server {
+	t.Helper()
+	store, err := database.Open(database.DriverSQLite, filepath.Join(t.TempDir(), "scheduler.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return NewServer(clock.Func(func() time.Time { return *now }), store)
+}
+
+// call выполняет запрос к обработчику и разбирает JSON-ответ.
+func call(t *testing.T, h http.HandlerFunc, method, target, body string) (int, map[string]any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m), rec.Body.String())
+	return rec.Code, m
+}
+
+// addTask создаёт задачу и возвращает её дату из базы.
+func addTask(t *testing.T, s *Server, date, repeat string) (int64, string) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"date": date, "title": "Задача", "repeat": repeat})
+	code, m := call(t, s.AddTaskHandler, http.MethodPost, "/api/task", string(body))
+	require.Equal(t, http.StatusCreated, code, m)
+	id, err := strconv.ParseInt(m["id"].(string), 10, 64)
+	require.NoError(t, err)
+	task, err := s.store.GetTaskByID(id)
+	require.NoError(t, err)
+	return id, task.Date
+}
+
+func TestAddTaskAroundMidnight(t *testing.T) {
+	// Пятница, 26 января 2024, за секунду до полуночи
+	now := time.Date(2024, 1, 26, 23, 59, 59, 0, time.UTC)
+	s := newTestServer(t, &now)
+
+	tbl := []struct {
+		date   string
+		repeat string
+		want   string
+	}{
+		{"20240126", "", "20240126"},
+		{"20240126", "w 5", "20240126"}, // сегодняшняя задача не уезжает на неделю вперёд
+		{"20240126", "d 1", "20240126"},
+		{"20240125", "", "20240126"},
+		{"20240125", "d 3", "20240128"},
+		{"", "m 1", "20240126"},
+	}
+	for _, v := range tbl {
+		_, got := addTask(t, s, v.date, v.repeat)
+		assert.Equal(t, v.want, got, "%s %s", v.date, v.repeat)
+	}
+
+	// Секунда спустя «сегодня» уже 27 января
+	now = now.Add(time.Second)
+	_, got := addTask(t, s, "20240126", "")
+	assert.Equal(t, "20240127", got)
+	_, got = addTask(t, s, "20240126", "w 5")
+	assert.Equal(t, "20240202", got)
+}
+
+func TestDoneTaskAroundMidnight(t *testing.T) {
+	now := time.Date(2024, 1, 26, 23, 59, 59, 0, time.UTC)
+	s := newTestServer(t, &now)
+
+	id, _ := addTask(t, s, "20240126", "d 1")
+	done := func() string {
+		code, m := call(t, s.DoneTaskHandler, http.MethodPost, "/api/task/done?id="+strconv.FormatInt(id, 10), "")
+		require.Equal(t, http.StatusOK, code, m)
+		task, err := s.store.GetTaskByID(id)
+		require.NoError(t, err)
+		return task.Date
+	}
+
+	assert.Equal(t, "20240127", done())
+	now = time.Date(2024, 1, 27, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, "20240128", done())
+}
+
+func TestTasksAroundMidnight(t *testing.T) {
+	now := time.Date(2024, 1, 26, 12, 0, 0, 0, time.UTC)
+	s := newTestServer(t, &now)
+
+	addTask(t, s, "20240126", "w 5")
+	addTask(t, s, "20240127", "w 6")
+
+	list := func() []string {
+		code, m := call(t, s.Tasks, http.MethodGet, "/api/tasks", "")
+		require.Equal(t, http.StatusOK, code, m)
+		var dates []string
+		for _, item := range m["list"].([]any) {
+			dates = append(dates, item.(map[string]any)["date"].(string))
+		}
+		return dates
+	}
+
+	now = time.Date(2024, 1, 26, 23, 59, 59, 0, time.UTC)
+	assert.Equal(t, []string{"20240126", "20240127"}, list())
+
+	// После полуночи пятничная задача просрочена и показывается на следующей пятнице
+	now = time.Date(2024, 1, 27, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{"20240127", "20240202"}, list())
+}
+
+func TestTaskTimeOfDay(t *testing.T) {
+	now := time.Date(2024, 1, 26, 9, 0, 0, 0, time.UTC)
+	s := newTestServer(t, &now)
+
+	add := func(body string) (int, map[string]any) {
+		return call(t, s.AddTaskHandler, http.MethodPost, "/api/task", body)
+	}
+	code, m := add(`{"date": "20240126", "title": "Стендап", "repeat": "d 1", "time": "10:00", "duration": 15}`)
+	require.Equal(t, http.StatusCreated, code, m)
+	standup := m["id"].(string)
+	code, m = add(`{"date": "20240126", "title": "Обед", "time": "13:00", "duration": "60"}`)
+	require.Equal(t, http.StatusCreated, code, m)
+	code, m = add(`{"date": "20240126", "title": "Весь день"}`)
+	require.Equal(t, http.StatusCreated, code, m)
+	code, m = add(`{"date": "20240126", "title": "Ранний", "time": "08:30"}`)
+	require.Equal(t, http.StatusCreated, code, m)
+
+	for _, body := range []string{
+		`{"title": "x", "time": "25:00"}`,
+		`{"title": "x", "time": "9:00"}`,
+		`{"title": "x", "duration": 15}`,
+		`{"title": "x", "time": "10:00", "duration": 1441}`,
+		`{"title": "x", "time": "10:00", "duration": "час"}`,
+	} {
+		code, m = add(body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+		assert.NotEmpty(t, m["error"], body)
+	}
+
+	// В пределах дня задачи идут по времени, задачи на весь день — первыми
+	code, m = call(t, s.Tasks, http.MethodGet, "/api/tasks", "")
+	require.Equal(t, http.StatusOK, code, m)
+	var titles []string
+	for _, item := range m["list"].([]any) {
+		titles = append(titles, item.(map[string]any)["title"].(string))
+	}
+	assert.Equal(t, []string{"Весь день", "Ранний", "Стендап", "Обед"}, titles)
+
+	// Отметка выполнения переносит задачу на следующий день в то же время
+	code, m = call(t, s.DoneTaskHandler, http.MethodPost, "/api/task/done?id="+standup, "")
+	require.Equal(t, http.StatusOK, code, m)
+	code, m = call(t, s.GetTaskHandler, http.MethodGet, "/api/task?id="+standup, "")
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, "20240127", m["date"])
+	assert.Equal(t, "10:00", m["time"])
+	assert.Equal(t, "15", m["duration"])
+
+	// Время можно снять, тогда задача снова на весь день
+	code, m = call(t, s.UpdateTaskHandler, http.MethodPut, "/api/task",
+		`{"id": "`+standup+`", "date": "20240127", "title": "Стендап", "repeat": "d 1"}`)
+	require.Equal(t, http.StatusOK, code, m)
+	id, _ := strconv.ParseInt(standup, 10, 64)
+	task, err := s.store.GetTaskByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, "", task.Time)
+	assert.Equal(t, 0, task.Duration)
+}
+
+func TestTaskTimeZones(t *testing.T) {
+	// В Москве уже 27 января, в Нью-Йорке ещё 26-е
+	now := time.Date(2024, 1, 26, 22, 30, 0, 0, time.UTC)
+	s := newTestServer(t, &now)
+
+	add := func(date, repeat, tz string) (int, map[string]any) {
+		body, _ := json.Marshal(map[string]string{"date": date, "title": "Задача " + tz, "repeat": repeat, "tz": tz})
+		return call(t, s.AddTaskHandler, http.MethodPost, "/api/task", string(body))
+	}
+	dateOf := func(m map[string]any) string {
+		id, err := strconv.ParseInt(m["id"].(string), 10, 64)
+		require.NoError(t, err)
+		task, err := s.store.GetTaskByID(id)
+		require.NoError(t, err)
+		return task.Date
+	}
+
+	code, m := add("20240126", "", "Europe/Moscow")
+	require.Equal(t, http.StatusCreated, code, m)
+	assert.Equal(t, "20240127", dateOf(m))
+	code, m = add("20240126", "", "")
+	require.Equal(t, http.StatusCreated, code, m)
+	assert.Equal(t, "20240126", dateOf(m))
+	code, m = add("20240126", "", "Mars/Olympus")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = add("20240126", "w 5", "Asia/Tokyo")
+	require.Equal(t, http.StatusCreated, code)
+	code, _ = add("20240126", "w 5", "America/New_York")
+	require.Equal(t, http.StatusCreated, code)
+
+	code, m = call(t, s.Tasks, http.MethodGet, "/api/tasks", "")
+	require.Equal(t, http.StatusOK, code, m)
+	got := map[string]string{}
+	for _, item := range m["list"].([]any) {
+		task := item.(map[string]any)
+		if task["repeat"] != "" {
+			got[task["tz"].(string)] = task["date"].(string)
+		}
+	}
+	assert.Equal(t, map[string]string{"Asia/Tokyo": "20240202", "America/New_York": "20240126"}, got)
+
+	// Ночь после перехода на летнее время: в 04:30 UTC в Нью-Йорке уже 00:30 11 марта
+	now = time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	code, m = add("20240310", "d 1", "America/New_York")
+	require.Equal(t, http.StatusCreated, code, m)
+	id := m["id"].(string)
+	now = time.Date(2024, 3, 11, 4, 30, 0, 0, time.UTC)
+	code, m = call(t, s.DoneTaskHandler, http.MethodPost, "/api/task/done?id="+id, "")
+	require.Equal(t, http.StatusOK, code, m)
+	code, m = call(t, s.GetTaskHandler, http.MethodGet, "/api/task?id="+id, "")
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, "20240312", m["date"])
+	assert.Equal(t, "America/New_York", m["tz"])
+}
+
+func TestSubDailyTasks(t *testing.T) {
+	now := time.Date(2024, 1, 26, 10, 30, 0, 0, time.UTC)
+	s := newTestServer(t, &now)
+
+	code, m := call(t, s.AddTaskHandler, http.MethodPost, "/api/task", `{"title": "Очередь", "repeat": "h 3"}`)
+	assert.Equal(t, http.StatusBadRequest, code, m)
+
+	// Прошедшее время начала сразу переносится на ближайшее повторение
+	code, m = call(t, s.AddTaskHandler, http.MethodPost, "/api/task",
+		`{"date": "20240126", "time": "08:00", "title": "Лекарство", "repeat": "h 3"}`)
+	require.Equal(t, http.StatusCreated, code, m)
+	id := m["id"].(string)
+
+	get := func() (string, string) {
+		code, m := call(t, s.GetTaskHandler, http.MethodGet, "/api/task?id="+id, "")
+		require.Equal(t, http.StatusOK, code, m)
+		return m["date"].(string), m["time"].(string)
+	}
+	date, start := get()
+	assert.Equal(t, "20240126 11:00", date+" "+start)
+
+	done := func() {
+		code, m := call(t, s.DoneTaskHandler, http.MethodPost, "/api/task/done?id="+id, "")
+		require.Equal(t, http.StatusOK, code, m)
+	}
+	now = time.Date(2024, 1, 26, 11, 5, 0, 0, time.UTC)
+	done()
+	date, start = get()
+	assert.Equal(t, "20240126 14:00", date+" "+start)
+
+	// Отметка после полуночи переносит задачу на следующий день
+	now = time.Date(2024, 1, 27, 0, 10, 0, 0, time.UTC)
+	done()
+	date, start = get()
+	assert.Equal(t, "20240127 02:00", date+" "+start)
+}
+
+func TestAddPastCountedTask(t *testing.T) {
+	now := time.Date(2024, 1, 16, 9, 0, 0, 0, time.UTC)
+	s := newTestServer(t, &now)
+
+	// Все три повторения, 1, 8 и 15 января, уже прошли
+	code, m := call(t, s.AddTaskHandler, http.MethodPost, "/api/task", `{"date": "20240101", "title": "Задача", "repeat": "d 7 x3"}`)
+	assert.Equal(t, http.StatusBadRequest, code, m)
+
+	// Из 1, 8, 15 и 22 января прошли три: задача встаёт на 22 января с одним повторением
+	id, date := addTask(t, s, "20240101", "d 7 x4")
+	assert.Equal(t, "20240122", date)
+	task, err := s.store.GetTaskByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, 1, task.Remaining)
+	code, m = call(t, s.DoneTaskHandler, http.MethodPost, "/api/task/done?id="+strconv.FormatInt(id, 10), "")
+	require.Equal(t, http.StatusOK, code, m)
+	_, err = s.store.GetTaskByID(id)
+	assert.ErrorIs(t, err, database.ErrTask)
+
+	// У правила h повторения считаются по времени: прошло только 08:00, осталось 10:00 и 12:00
+	code, m = call(t, s.AddTaskHandler, http.MethodPost, "/api/task",
+		`{"date": "20240116", "time": "08:00", "title": "Лекарство", "repeat": "h 2 x3"}`)
+	require.Equal(t, http.StatusCreated, code, m)
+	id, err = strconv.ParseInt(m["id"].(string), 10, 64)
+	require.NoError(t, err)
+	task, err = s.store.GetTaskByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, "20240116 10:00", task.Date+" "+task.Time)
+	assert.Equal(t, 2, task.Remaining)
+}
+
+func TestSkipTask(t *testing.T) {
+	// Понедельник, 1 января 2024
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestServer(t, &now)
+	id, _ := addTask(t, s, "20240101", "d 1")
+	target := func(date string) string {
+		return "/api/task/skip?id=" + strconv.FormatInt(id, 10) + "&date=" + date
+	}
+
+	// Будущее повторение просто запоминается, задача остаётся на месте
+	code, m := call(t, s.SkipTaskHandler, http.MethodPost, target("20240102"), "")
+	require.Equal(t, http.StatusOK, code, m)
+	task, err := s.store.GetTaskByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, "20240101", task.Date)
+	assert.Equal(t, []string{"20240102"}, task.ExDates)
+
+	// Выполнение перескакивает через исключение
+	code, m = call(t, s.DoneTaskHandler, http.MethodPost, "/api/task/done?id="+strconv.FormatInt(id, 10), "")
+	require.Equal(t, http.StatusOK, code, m)
+	task, err = s.store.GetTaskByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, "20240103", task.Date)
+
+	// Пропуск ближайшего повторения сразу переносит задачу, старые исключения забываются
+	code, m = call(t, s.SkipTaskHandler, http.MethodPost, target("20240103"), "")
+	require.Equal(t, http.StatusOK, code, m)
+	task, err = s.store.GetTaskByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, "20240104", task.Date)
+	assert.Empty(t, task.ExDates)
+
+	// Просроченная задача в списке тоже минует исключения
+	code, m = call(t, s.SkipTaskHandler, http.MethodPost, target("20240106"), "")
+	require.Equal(t, http.StatusOK, code, m)
+	now = time.Date(2024, 1, 6, 12, 0, 0, 0, time.UTC)
+	tasks, err := s.store.UpcomingTasks(now, database.Cursor{}, 0)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "20240107", tasks[0].Date)
+
+	code, _ = call(t, s.SkipTaskHandler, http.MethodPost, target("2024-01-05"), "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = call(t, s.SkipTaskHandler, http.MethodPost, "/api/task/skip?id=999&date=20240105", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	once, _ := addTask(t, s, "20240110", "")
+	code, _ = call(t, s.SkipTaskHandler, http.MethodPost, "/api/task/skip?id="+strconv.FormatInt(once, 10)+"&date=20240110", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestCompletionHistory(t *testing.T) {
+	now := time.Date(2024, 1, 1, 9, 15, 0, 0, time.UTC)
+	s := newTestServer(t, &now)
+	daily, _ := addTask(t, s, "20240101", "d 1")
+	once, _ := addTask(t, s, "20240102", "")
+	done := func(id int64) {
+		t.Helper()
+		code, m := call(t, s.DoneTaskHandler, http.MethodPost, "/api/task/done?id="+strconv.FormatInt(id, 10), "")
+		require.Equal(t, http.StatusOK, code, m)
+	}
+
+	done(daily)
+	now = time.Date(2024, 1, 2, 18, 0, 0, 0, time.UTC)
+	done(daily)
+	done(once)
+
+	code, m := call(t, s.TaskHistoryHandler, http.MethodGet, "/api/task/history?id="+strconv.FormatInt(daily, 10), "")
+	require.Equal(t, http.StatusOK, code, m)
+	history := m["completions"].([]any)
+	require.Len(t, history, 2)
+	assert.Equal(t, "20240101", history[0].(map[string]any)["date"])
+	assert.Equal(t, "20240101 09:15:00", history[0].(map[string]any)["done_at"])
+	assert.Equal(t, "20240102", history[1].(map[string]any)["date"])
+
+	// Разовая задача удалена, но её история осталась
+	_, err := s.store.GetTaskByID(once)
+	require.ErrorIs(t, err, database.ErrTask)
+	code, m = call(t, s.TaskHistoryHandler, http.MethodGet, "/api/task/history?id="+strconv.FormatInt(once, 10), "")
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Len(t, m["completions"], 1)
+
+	code, _ = call(t, s.TaskHistoryHandler, http.MethodGet, "/api/task/history?id=999", "")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	tbl := []struct {
+		query string
+		want  int
+	}{
+		{"", 3},
+		{"from=20240102", 2},
+		{"to=20240101", 1},
+		{"from=20240102&to=20240102", 2},
+		{"from=20240103", 0},
+	}
+	for _, v := range tbl {
+		code, m = call(t, s.CompletionsHandler, http.MethodGet, "/api/completions?"+v.query, "")
+		require.Equal(t, http.StatusOK, code, v.query)
+		assert.Len(t, m["completions"], v.want, v.query)
+	}
+
+	for _, q := range []string{"from=2024-01-01", "to=ooops", "from=20240105&to=20240101"} {
+		code, _ = call(t, s.CompletionsHandler, http.MethodGet, "/api/completions?"+q, "")
+		assert.Equal(t, http.StatusBadRequest, code, q)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	s := newTestServer(t, &now)
+	s.UndoWindow = time.Minute
+	// do выполняет запрос и возвращает токен отмены из заголовка
+	do := func(h http.HandlerFunc, method, target string) string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(method, target, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.JSONEq(t, `{}`, rec.Body.String())
+		token := rec.Header().Get("X-Undo-Token")
+		require.NotEmpty(t, token)
+		return token
+	}
+	undo := func(token string) (int, map[string]any) {
+		t.Helper()
+		return call(t, s.UndoTaskHandler, http.MethodPost, "/api/task/undo?token="+token, "")
+	}
+
+	// Выполнение разовой задачи удаляет её, отмена возвращает с тем же ID и без записи в истории
+	once, _ := addTask(t, s, "20240101", "")
+	token := do(s.DoneTaskHandler, http.MethodPost, "/api/task/done?id="+strconv.FormatInt(once, 10))
+	_, err := s.store.GetTaskByID(once)
+	require.ErrorIs(t, err, database.ErrTask)
+	code, m := undo(token)
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, strconv.FormatInt(once, 10), m["id"])
+	task, err := s.store.GetTaskByID(once)
+	require.NoError(t, err)
+	assert.Equal(t, "20240101", task.Date)
+	history, err := s.store.GetTaskHistory(once)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	// Токен одноразовый
+	code, _ = undo(token)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// Выполнение повторяющейся задачи откатывается на прежнюю дату и счётчик
+	daily, _ := addTask(t, s, "20240101", "d 1 x3")
+	token = do(s.DoneTaskHandler, http.MethodPost, "/api/task/done?id="+strconv.FormatInt(daily, 10))
+	code, m = undo(token)
+	require.Equal(t, http.StatusOK, code, m)
+	task, err = s.store.GetTaskByID(daily)
+	require.NoError(t, err)
+	assert.Equal(t, "20240101", task.Date)
+	assert.Equal(t, 3, task.Remaining)
+
+	// Удаление отменяется так же
+	token = do(s.DeleteTaskHandler, http.MethodDelete, "/api/task?id="+strconv.FormatInt(daily, 10))
+	code, m = undo(token)
+	require.Equal(t, http.StatusOK, code, m)
+	_, err = s.store.GetTaskByID(daily)
+	require.NoError(t, err)
+
+	// Правка после выполнения не теряется: отмена отвечает 412
+	edited, _ := addTask(t, s, "20240101", "d 1")
+	token = do(s.DoneTaskHandler, http.MethodPost, "/api/task/done?id="+strconv.FormatInt(edited, 10))
+	code, m = call(t, s.UpdateTaskHandler, http.MethodPut, "/api/task",
+		`{"id": "`+strconv.FormatInt(edited, 10)+`", "date": "20240110", "title": "Правка", "repeat": "d 1"}`)
+	require.Equal(t, http.StatusOK, code, m)
+	code, m = undo(token)
+	assert.Equal(t, http.StatusPreconditionFailed, code, m)
+	task, err = s.store.GetTaskByID(edited)
+	require.NoError(t, err)
+	assert.Equal(t, "Правка", task.Title)
+
+	// Отменить можно только последнее действие и только в пределах окна
+	first := do(s.DoneTaskHandler, http.MethodPost, "/api/task/done?id="+strconv.FormatInt(daily, 10))
+	last := do(s.DoneTaskHandler, http.MethodPost, "/api/task/done?id="+strconv.FormatInt(daily, 10))
+	code, _ = undo(first)
+	assert.Equal(t, http.StatusNotFound, code)
+	now = now.Add(2 * time.Minute)
+	code, _ = undo(last)
+	assert.Equal(t, http.StatusGone, code)
+
+	code, _ = undo("")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// При выключенной отмене токен не выдаётся
+	s.UndoWindow = 0
+	rec := httptest.NewRecorder()
+	s.DeleteTaskHandler(rec, httptest.NewRequest(http.MethodDelete, "/api/task?id="+strconv.FormatInt(daily, 10), nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("X-Undo-Token"))
+}
+
+func TestTrash(t *testing.T) {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	s := newTestServer(t, &now)
+	id, _ := addTask(t, s, "20240105", "")
+	kept, _ := addTask(t, s, "20240106", "")
+	idStr := strconv.FormatInt(id, 10)
+
+	code, m := call(t, s.DeleteTaskHandler, http.MethodDelete, "/api/task?id="+idStr, "")
+	require.Equal(t, http.StatusOK, code, m)
+
+	// Удалённая задача не видна ни по ID, ни в списке, но лежит в корзине
+	code, _ = call(t, s.GetTaskHandler, http.MethodGet, "/api/task?id="+idStr, "")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = call(t, s.DoneTaskHandler, http.MethodPost, "/api/task/done?id="+idStr, "")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, m = call(t, s.Tasks, http.MethodGet, "/api/tasks", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, m["list"], 1)
+	code, m = call(t, s.GetTasksHandler, http.MethodGet, "/api/tasks?search=Задача", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, m["tasks"], 1)
+
+	code, m = call(t, s.TrashHandler, http.MethodGet, "/api/trash", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, m["tasks"], 1)
+	item := m["tasks"].([]any)[0].(map[string]any)
+	assert.Equal(t, idStr, item["id"])
+	assert.Equal(t, "20240101 09:00:00", item["deleted_at"])
+
+	// Восстановленная задача возвращается на место
+	code, m = call(t, s.RestoreTaskHandler, http.MethodPost, "/api/trash/restore?id="+idStr, "")
+	require.Equal(t, http.StatusOK, code, m)
+	code, _ = call(t, s.GetTaskHandler, http.MethodGet, "/api/task?id="+idStr, "")
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = call(t, s.RestoreTaskHandler, http.MethodPost, "/api/trash/restore?id="+idStr, "")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// Очистка удаляет только то, что пролежало в корзине дольше срока
+	code, _ = call(t, s.DeleteTaskHandler, http.MethodDelete, "/api/task?id="+idStr, "")
+	require.Equal(t, http.StatusOK, code)
+	now = now.Add(time.Hour)
+	code, _ = call(t, s.DeleteTaskHandler, http.MethodDelete, "/api/task?id="+strconv.FormatInt(kept, 10), "")
+	require.Equal(t, http.StatusOK, code)
+	n, err := s.store.PurgeTrash(now.Add(-30 * time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	trash, err := s.store.GetTrash()
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.Equal(t, kept, trash[0].ID)
+}
+
+func TestETags(t *testing.T) {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	s := newTestServer(t, &now)
+	id, _ := addTask(t, s, "20240101", "d 1")
+	idStr := strconv.FormatInt(id, 10)
+	// send выполняет запрос с заголовком If-Match и возвращает код ответа и ETag
+	send := func(h http.HandlerFunc, method, target, body, match string) (int, string) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if match != "" {
+			req.Header.Set("If-Match", match)
+		}
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec.Code, rec.Header().Get("ETag")
+	}
+	get := func() string {
+		t.Helper()
+		code, tag := send(s.GetTaskHandler, http.MethodGet, "/api/task?id="+idStr, "", "")
+		require.Equal(t, http.StatusOK, code)
+		return tag
+	}
+	put := func(title, match string) int {
+		t.Helper()
+		body := `{"id":"` + idStr + `","date":"20240101","title":"` + title + `","repeat":"d 1"}`
+		code, _ := send(s.UpdateTaskHandler, http.MethodPut, "/api/task", body, match)
+		return code
+	}
+
+	first := get()
+	assert.Equal(t, `"1"`, first)
+
+	// Вторая вкладка с тем же ETag не затирает изменения первой
+	assert.Equal(t, http.StatusOK, put("Первая вкладка", first))
+	assert.Equal(t, http.StatusPreconditionFailed, put("Вторая вкладка", first))
+	task, err := s.store.GetTaskByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, "Первая вкладка", task.Title)
+	second := get()
+	assert.Equal(t, `"2"`, second)
+
+	// Без If-Match и с * запись проходит как раньше
+	assert.Equal(t, http.StatusOK, put("Без условия", ""))
+	assert.Equal(t, http.StatusOK, put("Любая версия", "*"))
+	assert.Equal(t, http.StatusPreconditionFailed, put("Слабый ETag", `W/"4"`))
+	assert.Equal(t, http.StatusPreconditionFailed, put("Без кавычек", "4"))
+
+	code, _ := send(s.DoneTaskHandler, http.MethodPost, "/api/task/done?id="+idStr, "", second)
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+	code, _ = send(s.DoneTaskHandler, http.MethodPost, "/api/task/done?id="+idStr, "", get())
+	assert.Equal(t, http.StatusOK, code)
+	task, err = s.store.GetTaskByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, "20240102", task.Date)
+
+	code, _ = send(s.DeleteTaskHandler, http.MethodDelete, "/api/task?id="+idStr, "", second)
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+	current := get()
+	code, _ = send(s.DeleteTaskHandler, http.MethodDelete, "/api/task?id="+idStr, "", current)
+	assert.Equal(t, http.StatusOK, code)
+
+	// После восстановления версия продолжает расти
+	code, m := call(t, s.RestoreTaskHandler, http.MethodPost, "/api/trash/restore?id="+idStr, "")
+	require.Equal(t, http.StatusOK, code, m)
+	assert.NotEqual(t, current, get())
+}
+
+func TestTasksPagination(t *testing.T) {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	s := newTestServer(t, &now)
+	var want []string
+	for i := range 51 {
+		// По две задачи на день: внутри дня порядок задаёт ID
+		id, _ := addTask(t, s, time.Date(2024, 1, 1+i/2, 0, 0, 0, 0, time.UTC).Format(layout), "")
+		want = append(want, strconv.FormatInt(id, 10))
+	}
+
+	// pages проходит список страницами и возвращает ID задач всех страниц и число страниц
+	pages := func(h http.HandlerFunc, target, key string) ([]string, int) {
+		var ids []string
+		n := 0
+		for cursor := ""; ; n++ {
+			code, m := call(t, h, http.MethodGet, target+"&cursor="+cursor, "")
+			require.Equal(t, http.StatusOK, code, m)
+			for _, item := range m[key].([]any) {
+				ids = append(ids, item.(map[string]any)["id"].(string))
+			}
+			next, ok := m["next_cursor"].(string)
+			if !ok {
+				return ids, n + 1
+			}
+			cursor = next
+		}
+	}
+
+	ids, n := pages(s.Tasks, "/api/tasks?limit=20", "list")
+	assert.Equal(t, want, ids)
+	assert.Equal(t, 3, n)
+	ids, n = pages(s.GetTasksHandler, "/api/tasks?search=Задача&limit=20", "tasks")
+	assert.Equal(t, want, ids)
+	assert.Equal(t, 3, n)
+
+	// Без limit отдаётся 50 задач
+	code, m := call(t, s.Tasks, http.MethodGet, "/api/tasks", "")
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Len(t, m["list"], 50)
+	assert.NotEmpty(t, m["next_cursor"])
+	code, m = call(t, s.GetTasksHandler, http.MethodGet, "/api/tasks?search=Задача&limit=51", "")
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Len(t, m["tasks"], 51)
+	assert.NotContains(t, m, "next_cursor")
+
+	for _, query := range []string{"limit=0", "limit=501", "limit=x", "cursor=%3F%3F", "cursor=" + encodeCursor(database.Cursor{Date: "2024", ID: 1})} {
+		code, _ = call(t, s.Tasks, http.MethodGet, "/api/tasks?"+query, "")
+		assert.Equal(t, http.StatusBadRequest, code, query)
+		code, _ = call(t, s.GetTasksHandler, http.MethodGet, "/api/tasks?search=Задача&"+query, "")
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestSearchQuery(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	s := newTestServer(t, &now)
+	monthly, _ := addTask(t, s, "20260302", "m 2")
+	once, _ := addTask(t, s, "20260315", "")
+	addTask(t, s, "20260401", "")
+
+	search := func(query string) (int, map[string]any) {
+		t.Helper()
+		return call(t, s.GetTasksHandler, http.MethodGet, "/api/tasks?search="+url.QueryEscape(query), "")
+	}
+	ids := func(m map[string]any) []string {
+		var ids []string
+		for _, item := range m["tasks"].([]any) {
+			ids = append(ids, item.(map[string]any)["id"].(string))
+		}
+		return ids
+	}
+
+	code, m := search("from:01.03.2026 to:31.03.2026")
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, []string{strconv.FormatInt(monthly, 10), strconv.FormatInt(once, 10)}, ids(m))
+	code, m = search(`title:"Задача" -repeat:none to:31.03.2026`)
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, []string{strconv.FormatInt(monthly, 10)}, ids(m))
+	code, m = search("15.03.2026")
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, []string{strconv.FormatInt(once, 10)}, ids(m))
+
+	// Неизвестное «поле» ищется как текст
+	code, m = search("re:встреча")
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Empty(t, ids(m))
+
+	tbl := []struct {
+		query string
+		want  string
+	}{
+		{`"Задача`, "не закрыта кавычка"},
+		{"from:2026-03-01", "некорректная дата «2026-03-01» у поля from"},
+		{"from:31.03.2026 to:01.03.2026", "дата to раньше даты from"},
+		{"title:", "у поля title нет значения"},
+	}
+	for _, v := range tbl {
+		code, m := search(v.query)
+		assert.Equal(t, http.StatusBadRequest, code, v.query)
+		assert.Contains(t, m["error"], v.want, v.query)
+	}
+}
+
+// brokenTrash — хранилище, у которого отправка в корзину всегда завершается ошибкой
+type brokenTrash struct {
+	database.TaskStore
+}
+
+func (brokenTrash) TrashTask(int64, time.Time, database.Undo, int64) error {
+	return errors.New("диск переполнен")
+}
+
+func TestDeleteTaskErrors(t *testing.T) {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	s := newTestServer(t, &now)
+	id, _ := addTask(t, s, "20240105", "")
+
+	code, _ := call(t, s.DeleteTaskHandler, http.MethodDelete, "/api/task?id=999", "")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// Сбой хранилища — это не «задача не найдена»
+	s.store = brokenTrash{s.store}
+	code, m := call(t, s.DeleteTaskHandler, http.MethodDelete, "/api/task?id="+strconv.FormatInt(id, 10), "")
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, "Ошибка при удалении задачи", m["error"])
+}
+
+// racingSkip — хранилище, в котором сразу после чтения задачи её повторение
+// пропускает кто-то другой, пока skips не закончатся
+type racingSkip struct {
+	database.TaskStore
+	now   time.Time
+	skips []string
+}
+
+func (s *racingSkip) GetTaskByID(id int64) (database.Task, error) {
+	task, err := s.TaskStore.GetTaskByID(id)
+	if err == nil && len(s.skips) > 0 {
+		err = s.TaskStore.SkipTask(id, s.skips[0], s.now)
+		s.skips = s.skips[1:]
+	}
+	return task, err
+}
+
+func TestUpdateTaskConcurrentSkip(t *testing.T) {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	s := newTestServer(t, &now)
+	id, _ := addTask(t, s, "20240101", "d 1")
+	idStr := strconv.FormatInt(id, 10)
+	body := `{"id": "` + idStr + `", "date": "20240101", "title": "Правка", "repeat": "d 1"}`
+
+	// Пропуск между чтением и записью не теряется: PUT перечитывает задачу
+	s.store = &racingSkip{TaskStore: s.store, now: now, skips: []string{"20240105"}}
+	code, m := call(t, s.UpdateTaskHandler, http.MethodPut, "/api/task", body)
+	require.Equal(t, http.StatusOK, code, m)
+	task, err := s.store.GetTaskByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, "Правка", task.Title)
+	assert.Equal(t, []string{"20240105"}, task.ExDates)
+
+	// Если задачу меняют после каждого чтения, PUT сдаётся и отвечает 412, ничего не затирая
+	s.store = &racingSkip{TaskStore: s.store.(*racingSkip).TaskStore, now: now, skips: []string{"20240106", "20240107", "20240108"}}
+	code, _ = call(t, s.UpdateTaskHandler, http.MethodPut, "/api/task", strings.Replace(body, "Правка", "Вторая", 1))
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+	task, err = s.store.GetTaskByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, "Правка", task.Title)
+	assert.Equal(t, []string{"20240105", "20240106", "20240107", "20240108"}, task.ExDates)
+}
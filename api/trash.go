@@ -0,0 +1,74 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/naluneotlichno/FP-GO-API/database"
+	"github.com/naluneotlichno/FP-GO-API/nextdate"
+)
+
+// TrashItem — задача в корзине: поля задачи и момент удаления
+type TrashItem struct {
+	TaskResponseItem
+	DeletedAt string `json:"deleted_at"`
+}
+
+// TrashResponse — ответ /api/trash
+type TrashResponse struct {
+	Tasks []TrashItem `json:"tasks"`
+}
+
+// TrashHandler обрабатывает GET /api/trash
+func (s *Server) TrashHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("🔥 [TrashHandler] Запрос на /api/trash получен...")
+
+	tasks, err := s.store.GetTrash()
+	if err != nil {
+		log.Printf("🚨 [TrashHandler] Ошибка чтения корзины: %v\n", err)
+		JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка чтения корзины"})
+		return
+	}
+
+	response := TrashResponse{Tasks: []TrashItem{}}
+	locale := nextdate.LocaleFromRequest(r)
+	for _, t := range tasks {
+		response.Tasks = append(response.Tasks, TrashItem{
+			TaskResponseItem: taskResponseItem(t, locale),
+			DeletedAt:        t.DeletedAt,
+		})
+	}
+
+	JsonResponse(w, http.StatusOK, response)
+}
+
+// RestoreTaskHandler обрабатывает POST /api/trash/restore?id=...
+func (s *Server) RestoreTaskHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("🔥 [RestoreTaskHandler] Запрос на /api/trash/restore получен...")
+
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		JsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Не указан идентификатор"})
+		return
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		JsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Некорректный идентификатор"})
+		return
+	}
+
+	if err := s.store.RestoreTask(id); err != nil {
+		if errors.Is(err, database.ErrTask) {
+			JsonResponse(w, http.StatusNotFound, map[string]string{"error": "Задачи нет в корзине"})
+			return
+		}
+		log.Printf("🚨 [RestoreTaskHandler] Ошибка восстановления задачи ID=%d: %v\n", id, err)
+		JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка при восстановлении задачи"})
+		return
+	}
+
+	JsonResponse(w, http.StatusOK, map[string]any{})
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/naluneotlichno/FP-GO-API/database"
+)
+
+// Токен отмены отдаётся в заголовке, чтобы тело ответов done и delete осталось пустым
+const (
+	undoTokenHeader   = "X-Undo-Token"
+	undoExpiresHeader = "X-Undo-Expires"
+)
+
+// newUndo выдаёт токен отмены, действующий UndoWindow от now.
+// Если отмена выключена, возвращается пустой токен.
+func (s *Server) newUndo(now time.Time) (database.Undo, error) {
+	if s.UndoWindow <= 0 {
+		return database.Undo{}, nil
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return database.Undo{}, err
+	}
+	return database.Undo{Token: hex.EncodeToString(b), IssuedAt: now, ExpiresAt: now.Add(s.UndoWindow)}, nil
+}
+
+// setUndoHeader сообщает клиенту токен отмены и срок его действия в RFC 3339
+func setUndoHeader(w http.ResponseWriter, undo database.Undo) {
+	if undo.Token == "" {
+		return
+	}
+	w.Header().Set(undoTokenHeader, undo.Token)
+	w.Header().Set(undoExpiresHeader, undo.ExpiresAt.Format(time.RFC3339))
+}
+
+// UndoTaskHandler обрабатывает POST /api/task/undo?token=...
+// Возвращает задачу в состояние до выполнения или удаления, выдавшего токен.
+func (s *Server) UndoTaskHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("🔥 [UndoTaskHandler] Запрос на /api/task/undo получен...")
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		JsonResponse(w, http.StatusBadRequest, map[string]string{"error": "Не указан токен отмены"})
+		return
+	}
+
+	task, err := s.store.UndoTask(token, s.now(r))
+	switch {
+	case errors.Is(err, database.ErrUndo):
+		JsonResponse(w, http.StatusNotFound, map[string]string{"error": "Нечего отменять"})
+		return
+	case errors.Is(err, database.ErrUndoExpired):
+		JsonResponse(w, http.StatusGone, map[string]string{"error": "Время на отмену истекло"})
+		return
+	case errors.Is(err, database.ErrVersion):
+		JsonResponse(w, http.StatusPreconditionFailed, map[string]string{"error": "Задачу изменили после этого действия, отменить его нельзя"})
+		return
+	case err != nil:
+		log.Printf("🚨 [UndoTaskHandler] Ошибка отмены: %v\n", err)
+		JsonResponse(w, http.StatusInternalServerError, map[string]string{"error": "Ошибка при отмене"})
+		return
+	}
+
+	log.Printf("✅ [UndoTaskHandler] Задача ID=%d восстановлена\n", task.ID)
+	JsonResponse(w, http.StatusOK, map[string]string{"id": strconv.FormatInt(task.ID, 10)})
+}
@@ -0,0 +1,58 @@
+// Package clock отделяет обработчики и хранилище от системных часов,
+// чтобы поведение около полуночи можно было проверить тестами.
+package clock
+
+import (
+	"net/http"
+	"time"
+)
+
+// Clock — источник текущего времени.
+type Clock interface {
+	Now() time.Time
+}
+
+// Func позволяет использовать обычную функцию как Clock.
+type Func func() time.Time
+
+// Now возвращает f().
+func (f Func) Now() time.Time {
+	return f()
+}
+
+// System — системные часы.
+var System Clock = Func(time.Now)
+
+// Fixed возвращает часы, которые всегда показывают t.
+func Fixed(t time.Time) Clock {
+	return Func(func() time.Time { return t })
+}
+
+// FromRequest возвращает текущее время для обработки запроса r.
+// В тестовой сборке (go build -tags testclock) время можно подменить
+// параметром now в формате YYYYMMDD или RFC 3339; в обычной сборке
+// параметр игнорируется и время всегда берётся из c.
+func FromRequest(c Clock, r *http.Request) time.Time {
+	now := c.Now()
+	if allowOverride {
+		if t, ok := parseNow(r.URL.Query().Get("now"), now.Location()); ok {
+			return t
+		}
+	}
+	return now
+}
+
+// parseNow разбирает значение параметра now. Дата без времени означает
+// полночь в часовом поясе loc.
+func parseNow(s string, loc *time.Location) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.ParseInLocation("20060102", s, loc); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(loc), true
+	}
+	return time.Time{}, false
+}
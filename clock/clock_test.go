@@ -0,0 +1,50 @@
+package clock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromRequest(t *testing.T) {
+	fixed := time.Date(2024, 1, 26, 23, 59, 59, 0, time.UTC)
+	c := Fixed(fixed)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+	assert.Equal(t, fixed, FromRequest(c, r))
+
+	r = httptest.NewRequest(http.MethodGet, "/api/tasks?now=20240127", nil)
+	if allowOverride {
+		assert.Equal(t, time.Date(2024, 1, 27, 0, 0, 0, 0, time.UTC), FromRequest(c, r))
+	} else {
+		assert.Equal(t, fixed, FromRequest(c, r))
+	}
+
+	// Некорректное значение не подменяет время даже в тестовой сборке
+	r = httptest.NewRequest(http.MethodGet, "/api/tasks?now=yesterday", nil)
+	assert.Equal(t, fixed, FromRequest(c, r))
+}
+
+func TestZones(t *testing.T) {
+	_, err := LoadZone("Mars/Olympus")
+	assert.Error(t, err)
+	loc, err := LoadZone("")
+	assert.NoError(t, err)
+	assert.Nil(t, loc)
+
+	// 10 марта 2024 в Нью-Йорке перешли на летнее время (UTC-4): в 04:30 UTC там уже
+	// 00:30 следующего дня, хотя по зимнему смещению UTC-5 было бы ещё 23:30
+	utc := time.Date(2024, 3, 11, 4, 30, 0, 0, time.UTC)
+	assert.Equal(t, "20240311", In(utc, "America/New_York").Format("20060102"))
+	assert.Equal(t, "20240310", In(utc.Add(-time.Hour), "America/New_York").Format("20060102"))
+
+	// Неизвестный или пустой пояс оставляет время как есть
+	assert.Equal(t, utc, In(utc, ""))
+	assert.Equal(t, utc, In(utc, "Mars/Olympus"))
+
+	c := InZone(Fixed(utc), time.FixedZone("UTC+3", 3*3600))
+	assert.Equal(t, 7, c.Now().Hour())
+}
@@ -0,0 +1,6 @@
+//go:build !testclock
+
+package clock
+
+// allowOverride разрешает подменять время параметром now. В обычной сборке выключено.
+const allowOverride = false
@@ -0,0 +1,7 @@
+//go:build testclock
+
+package clock
+
+// allowOverride разрешает подменять время параметром now: сборка с тегом testclock
+// предназначена только для тестов.
+const allowOverride = true
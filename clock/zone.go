@@ -0,0 +1,42 @@
+package clock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// zones кэширует загруженные часовые пояса: time.LoadLocation каждый раз читает базу tzdata.
+var zones sync.Map
+
+// LoadZone загружает часовой пояс IANA по имени, например "Europe/Moscow".
+// Пустое имя даёт nil без ошибки: время остаётся в поясе по умолчанию.
+func LoadZone(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if loc, ok := zones.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("неизвестный часовой пояс [%s]: %w", name, err)
+	}
+	zones.Store(name, loc)
+	return loc, nil
+}
+
+// In переводит t в часовой пояс zone. Пустой или неизвестный пояс оставляет t как есть.
+// Переход на летнее время учитывается базой tzdata: «сегодня» меняется ровно в местную полночь.
+func In(t time.Time, zone string) time.Time {
+	loc, err := LoadZone(zone)
+	if err != nil || loc == nil {
+		return t
+	}
+	return t.In(loc)
+}
+
+// InZone возвращает часы, которые показывают время c в часовом поясе loc.
+func InZone(c Clock, loc *time.Location) Clock {
+	return Func(func() time.Time { return c.Now().In(loc) })
+}
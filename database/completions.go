@@ -0,0 +1,77 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// DoneAtLayout — формат момента выполнения в таблице task_completions.
+// Строки в этом формате сравниваются так же, как время.
+const DoneAtLayout = "20060102 15:04:05"
+
+// Completion — запись о выполнении задачи
+type Completion struct {
+	ID     int64  `json:"id"`
+	TaskID int64  `json:"task_id"`
+	Title  string `json:"title"`
+	// Date и Time — выполненное повторение: дата YYYYMMDD и время HH:MM, если оно было задано
+	Date string `json:"date"`
+	Time string `json:"time"`
+	// DoneAt — когда задачу отметили выполненной, в формате DoneAtLayout
+	DoneAt string `json:"done_at"`
+}
+
+// GetTaskHistory возвращает историю выполнения задачи, от ранних записей к поздним
+func (s *SQLStore) GetTaskHistory(taskID int64) ([]Completion, error) {
+	return s.queryCompletions("WHERE task_id = ?", taskID)
+}
+
+// GetCompletions возвращает выполнения за даты from..to включительно (YYYYMMDD).
+// Пустая граница означает, что с этой стороны диапазон не ограничен.
+func (s *SQLStore) GetCompletions(from, to string) ([]Completion, error) {
+	where, args := "WHERE 1 = 1", []any{}
+	if from != "" {
+		where += " AND done_at >= ?"
+		args = append(args, from)
+	}
+	if to != "" {
+		end, err := dayAfter(to)
+		if err != nil {
+			return nil, err
+		}
+		where += " AND done_at < ?"
+		args = append(args, end)
+	}
+	return s.queryCompletions(where, args...)
+}
+
+// dayAfter возвращает дату YYYYMMDD, следующую за to: выполнения за день to
+// записаны раньше неё
+func dayAfter(to string) (string, error) {
+	day, err := time.Parse("20060102", to)
+	if err != nil {
+		return "", fmt.Errorf("некорректная дата %q: %w", to, err)
+	}
+	return day.AddDate(0, 0, 1).Format("20060102"), nil
+}
+
+func (s *SQLStore) queryCompletions(where string, args ...any) ([]Completion, error) {
+	rows, err := s.conn().Query("SELECT id, task_id, title, date, time, done_at FROM task_completions "+where+" ORDER BY done_at, id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выполнении запроса: %w", err)
+	}
+	defer rows.Close()
+
+	completions := []Completion{}
+	for rows.Next() {
+		var c Completion
+		if err := rows.Scan(&c.ID, &c.TaskID, &c.Title, &c.Date, &c.Time, &c.DoneAt); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки из результата: %w", err)
+		}
+		completions = append(completions, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке результатов запроса: %w", err)
+	}
+	return completions, nil
+}
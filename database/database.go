@@ -7,22 +7,60 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"sort"
+	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/naluneotlichno/FP-GO-API/nextdate"
 )
 
-var db *sql.DB
 var ErrTask = errors.New("задача не найдена")
 
+// ErrVersion — задачу успели изменить: её версия не совпала с ожидаемой
+var ErrVersion = errors.New("версия задачи не совпадает")
+
 type Task struct {
 	ID      int64  `json:"id"`
 	Date    string `json:"date"`
 	Title   string `json:"title"`
 	Comment string `json:"comment"`
 	Repeat  string `json:"repeat"`
+	// Remaining — сколько повторений осталось, включая текущее; 0 — без ограничения
+	Remaining int `json:"remaining"`
+	// Time — время начала в формате HH:MM, пустое — задача на весь день
+	Time string `json:"time"`
+	// Duration — длительность в минутах, 0 — не указана
+	Duration int `json:"duration"`
+	// TZ — часовой пояс IANA, в котором считается «сегодня»; пустой — пояс сервера
+	TZ string `json:"tz"`
+	// ExDates — даты-исключения YYYYMMDD, в которые повторения пропускаются
+	ExDates []string `json:"exdates"`
+	// DeletedAt — когда задачу отправили в корзину, в формате DoneAtLayout; пустое — не в корзине
+	DeletedAt string `json:"deleted_at"`
+	// Version увеличивается при каждом изменении задачи
+	Version int64 `json:"version"`
+}
+
+// joinDates и splitDates переводят даты-исключения в строку через запятую и обратно:
+// в таком виде они хранятся в колонке exdates.
+func joinDates(dates []string) string {
+	return strings.Join(dates, ",")
+}
+
+func splitDates(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// normalizeRepeat проверяет правило повторения задачи и приводит его к каноническому виду
+func normalizeRepeat(task *Task) (nextdate.RepeatRule, error) {
+	rule, err := nextdate.Parse(task.Repeat)
+	if err != nil {
+		return rule, fmt.Errorf("ошибка в правиле повторения: %w", err)
+	}
+	task.Repeat = rule.String()
+	return rule, nil
 }
 
 // GetDBPath возвращает путь к файлу базы данных
@@ -44,57 +82,12 @@ func GetDBPath() string {
 	return dbPath
 }
 
-// InitDB создаёт таблицу scheduler, если её нет
-func InitDB(dbPath string) error {
-	var err error
-	db, err = sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return fmt.Errorf("❌ Ошибка при открытии базы данных: %w", err)
-	}
-
-	log.Printf("✅🔌 Подключаемся к базе: %s", dbPath)
-
-	if err := db.Ping(); err != nil {
-		return fmt.Errorf("❌ Не удалось подключиться к базе: %w", err)
-	}
-
-	createTableSQL := `
-	CREATE TABLE IF NOT EXISTS scheduler (
-		id INTEGER PRIMARY KEY AUTOINCREMENT, 
-		date TEXT NOT NULL,
-		title TEXT NOT NULL, 
-		comment TEXT, 
-		repeat TEXT(128)
-	);
-	CREATE INDEX IF NOT EXISTS idx_date ON scheduler(date); 
-	CREATE INDEX IF NOT EXISTS idx_title ON scheduler(title);
-	`
-
-	_, err = db.Exec(createTableSQL)
-	if err != nil {
-		log.Printf("❌ Ошибка при создании таблицы: %v", err)
-		return fmt.Errorf("❌ Ошибка при создании таблицы: %w", err)
-	}
-
-	log.Printf("✅ Таблица scheduler в [%s] создана или уже существует", dbPath)
-	return nil
+// DeleteTask удаляет задачу по её ID окончательно, минуя корзину
+func (s *SQLStore) DeleteTask(id int64) error {
+	return deleteTask(s.conn(), id)
 }
 
-// GetDB возвращает экземпляр базы данных
-func GetDB() (*sql.DB, error) {
-	if db == nil {
-		return nil, fmt.Errorf("❌ База данных не инициализирована. Сначала вызовите InitDB()")
-	}
-	return db, nil
-}
-
-// DeleteTask удаляет задачу по её ID
-func DeleteTask(id int64) error {
-	dbInstance, err := GetDB()
-	if err != nil {
-		return err
-	}
-
+func deleteTask(dbInstance execer, id int64) error {
 	res, err := dbInstance.Exec("DELETE FROM scheduler WHERE id = ?", id)
 	if err != nil {
 		log.Printf("🚨 [DeleteTask] Ошибка выполнения DELETE: %v\n", err)
@@ -116,25 +109,30 @@ func DeleteTask(id int64) error {
 	return nil
 }
 
-// UpdateTask обновляет существующую задачу
-func UpdateTask(task Task) error {
-	_, err := nextdate.NextDate(time.Now(), task.Date, task.Repeat, "check")
-	if err != nil {
-		return fmt.Errorf("ошибка при вычислении следующей даты: %w", err)
-	}
+// UpdateTask обновляет существующую задачу и увеличивает её версию.
+// Если task.Version задана, задача обновляется, только пока её версия не изменилась,
+// иначе возвращается ErrVersion.
+func (s *SQLStore) UpdateTask(task Task) error {
+	return updateTask(s.conn(), task, nil)
+}
 
-	dbInstance, err := GetDB()
-	if err != nil {
+// updateTask записывает задачу вместе с её местом в списке предстоящих.
+// loc — пояс «сегодня» для задачи без своего пояса, см. refreshAt; nil — неизвестен.
+func updateTask(dbInstance execer, task Task, loc *time.Location) error {
+	if _, err := normalizeRepeat(&task); err != nil {
 		return err
 	}
 
 	query := `
 		UPDATE scheduler
-		SET date = ?, title = ?, comment = ?, repeat = ?
-		WHERE id = ?
+		SET date = ?, title = ?, comment = ?, "repeat" = ?, remaining = ?, time = ?, duration = ?, tz = ?, exdates = ?,
+		    next_date = ?, refresh_at = ?, title_lower = ?, comment_lower = ?, version = version + 1
+		WHERE id = ? AND deleted_at = '' AND (? = 0 OR version = ?)
 	`
 
-	res, err := dbInstance.Exec(query, task.Date, task.Title, task.Comment, task.Repeat, task.ID)
+	next := nextdate.JoinDateTime(task.Date, task.Time)
+	res, err := dbInstance.Exec(query, task.Date, task.Title, task.Comment, task.Repeat, task.Remaining, task.Time, task.Duration, task.TZ, joinDates(task.ExDates),
+		next, refreshAt(task, next, false, loc), strings.ToLower(task.Title), strings.ToLower(task.Comment), task.ID, task.Version, task.Version)
 	if err != nil {
 		return fmt.Errorf("ошибка при обновлении задачи: %w", err)
 	}
@@ -145,6 +143,10 @@ func UpdateTask(task Task) error {
 	}
 
 	if rowsAffected == 0 {
+		// Задача есть, но её версия уже другая
+		if _, err := getTaskByID(dbInstance, task.ID, ""); err == nil && task.Version != 0 {
+			return ErrVersion
+		}
 		return ErrTask
 	}
 
@@ -153,16 +155,29 @@ func UpdateTask(task Task) error {
 }
 
 // GetTaskByID возвращает задачу по её ID
-func GetTaskByID(id int64) (Task, error) {
-	var task Task
-	log.Println("🔍 [GetTaskByID] Выполняем SELECT...")
-	query := "SELECT id, date, title, comment, repeat FROM scheduler WHERE id = ?"
-	dbInstance, err := GetDB()
-	if err != nil {
-		return Task{}, err
-	}
+func (s *SQLStore) GetTaskByID(id int64) (Task, error) {
+	return getTaskByID(s.conn(), id, "")
+}
+
+// taskColumns — колонки задачи в порядке, в котором их читает scanTask
+const taskColumns = `id, date, title, comment, "repeat", remaining, time, duration, tz, exdates, deleted_at, version`
+
+// scanTask читает задачу из строки, выбранной с колонками taskColumns
+func scanTask(row interface{ Scan(...any) error }) (Task, error) {
+	var (
+		task    Task
+		exdates string
+	)
+	err := row.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.Remaining, &task.Time, &task.Duration, &task.TZ, &exdates, &task.DeletedAt, &task.Version)
+	task.ExDates = splitDates(exdates)
+	return task, err
+}
 
-	err = dbInstance.QueryRow(query, id).Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+// getTaskByID читает задачу; lock дописывается к запросу, чтобы заблокировать строку до конца транзакции
+func getTaskByID(dbInstance execer, id int64, lock string) (Task, error) {
+	log.Println("🔍 [GetTaskByID] Выполняем SELECT...")
+	query := "SELECT " + taskColumns + " FROM scheduler WHERE id = ? AND deleted_at = ''" + lock
+	task, err := scanTask(dbInstance.QueryRow(query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			log.Printf("🚨 [GetTaskByID] Задача ID=%d не найдена\n", id)
@@ -176,83 +191,78 @@ func GetTaskByID(id int64) (Task, error) {
 }
 
 // AddTask добавляет новую задачу и возвращает её ID
-func AddTask(t Task) (int64, error) {
-	dbInstance, err := GetDB()
+func (s *SQLStore) AddTask(t Task) (int64, error) {
+	rule, err := normalizeRepeat(&t)
 	if err != nil {
 		return 0, err
 	}
+	if t.Remaining == 0 {
+		t.Remaining = rule.Count
+	}
 
-	query := "INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)"
+	query := `INSERT INTO scheduler (date, title, comment, "repeat", remaining, time, duration, tz, exdates, next_date, refresh_at, title_lower, comment_lower)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	res, err := dbInstance.Exec(query, t.Date, t.Title, t.Comment, t.Repeat)
+	next := nextdate.JoinDateTime(t.Date, t.Time)
+	id, err := s.dialect.insert(s.conn(), query, t.Date, t.Title, t.Comment, t.Repeat, t.Remaining, t.Time, t.Duration, t.TZ, joinDates(t.ExDates),
+		next, refreshAt(t, next, false, nil), strings.ToLower(t.Title), strings.ToLower(t.Comment))
 	if err != nil {
 		return 0, fmt.Errorf("ошибка при добавлении задачи: %w", err)
 	}
 
-	id, err := res.LastInsertId()
-	if err != nil {
-		return 0, fmt.Errorf("ошибка при получении ID последней вставленной записи: %w", err)
-	}
-
 	log.Printf("✅ [AddTask] Задача добавлена с ID=%d\n", id)
 	return id, nil
 }
 
-// GetUpcomingTasks возвращает список предстоящих задач
-func GetUpcomingTasks() ([]Task, error) {
-	dbInstance, err := GetDB()
-	if err != nil {
-		return nil, err
+// FindTasks возвращает задачи не из корзины, подходящие под фильтр f,
+// упорядоченные по дате, затем по времени и ID
+func (s *SQLStore) FindTasks(f TaskFilter) ([]Task, error) {
+	query, args := "SELECT "+taskColumns+" FROM scheduler WHERE deleted_at = ''", []any{}
+	if f.Date != "" {
+		query += " AND date = ?"
+		args = append(args, f.Date)
+	}
+	terms := f.Terms
+	if f.Text != "" {
+		terms = append([]Term{{Value: f.Text}}, terms...)
 	}
+	for _, t := range terms {
+		cond, termArgs := t.sql()
+		query += " AND " + cond
+		args = append(args, termArgs...)
+	}
+	if !f.After.IsZero() {
+		date, hhmm := nextdate.SplitDateTime(f.After.Date)
+		query += " AND (date, time, id) > (?, ?, ?)"
+		args = append(args, date, hhmm, f.After.ID)
+	}
+	query += " ORDER BY date, time, id"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+	return queryTasks(s.conn(), query, args...)
+}
 
-	query := "SELECT id, date, title, comment, repeat FROM scheduler"
-	rows, err := dbInstance.Query(query)
+// queryTasks выполняет запрос, выбирающий колонки taskColumns, и читает все задачи
+func queryTasks(dbInstance execer, query string, args ...any) ([]Task, error) {
+	rows, err := dbInstance.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка при выполнении запроса: %w", err)
 	}
 	defer rows.Close()
 
 	tasks := []Task{}
-	now := time.Now()
-
 	for rows.Next() {
-		var task Task
-		err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+		task, err := scanTask(rows)
 		if err != nil {
 			return nil, fmt.Errorf("ошибка при чтении строки из результата: %w", err)
 		}
-
-		// Предполагается, что формат даты - "20060102". Измени его, если используется другой формат.
-		taskDate, err := time.Parse("20060102", task.Date)
-		if err != nil {
-			return nil, fmt.Errorf("ошибка при разборе даты задачи ID %d: %w", task.ID, err)
-		}
-
-		if taskDate.Before(now) || taskDate.Equal(now) {
-			nextDateStr, err := nextdate.NextDate(now, task.Date, task.Repeat, "list")
-			if err != nil {
-				return nil, fmt.Errorf("ошибка при вычислении следующей даты для задачи ID %d: %w", task.ID, err)
-			}
-			task.Date = nextDateStr
-		}
 		tasks = append(tasks, task)
 	}
-
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("ошибка при обработке результатов запроса: %w", err)
 	}
-
-	// Сортировка задач по дате
-	sort.Slice(tasks, func(i, j int) bool {
-		return tasks[i].Date < tasks[j].Date
-	})
-
-	// Ограничение списка задач до 50
-	if len(tasks) > 50 {
-		tasks = tasks[:50]
-	}
-
-	log.Printf("✅ [GetUpcomingTasks] Получено %d задач\n", len(tasks))
 	return tasks, nil
 }
 
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/naluneotlichno/FP-GO-API/clock"
+	"github.com/naluneotlichno/FP-GO-API/nextdate"
+)
+
+// DoneTask отмечает выполнение задачи id на момент now. Чтение задачи, расчёт
+// следующей даты, запись в историю и перенос или удаление задачи выполняются
+// в одной транзакции, так что одновременные запросы не перескочат повторение
+// и не перенесут задачу дважды. Если у undo задан токен, прежнее состояние
+// задачи запоминается, чтобы выполнение можно было отменить.
+// Если version не 0, а версия задачи уже другая, возвращается ErrVersion.
+// Возвращает true, если повторений больше нет и задача удалена.
+func (s *SQLStore) DoneTask(id int64, now time.Time, undo Undo, version int64) (bool, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	task, err := getTaskByID(tx, id, s.dialect.forUpdate)
+	if err != nil {
+		return false, err
+	}
+	if version != 0 && task.Version != version {
+		return false, ErrVersion
+	}
+
+	completionID, err := s.dialect.insert(tx, "INSERT INTO task_completions (task_id, title, date, time, done_at) VALUES (?, ?, ?, ?, ?)",
+		task.ID, task.Title, task.Date, task.Time, now.Format(DoneAtLayout))
+	if err != nil {
+		return false, fmt.Errorf("ошибка при записи выполнения: %w", err)
+	}
+
+	if err := saveUndo(tx, undo, task, completionID, task.Version+1); err != nil {
+		return false, err
+	}
+
+	finished, err := advance(&task, now)
+	if err != nil {
+		return false, err
+	}
+	if finished {
+		log.Printf("🔍 [DoneTask] Повторений больше нет. Удаляем задачу ID=%d\n", id)
+		err = deleteTask(tx, id)
+	} else {
+		err = updateTask(tx, task, clock.In(now, task.TZ).Location())
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("ошибка при фиксации транзакции: %w", err)
+	}
+	log.Printf("✅ [DoneTask] Выполнение задачи ID=%d записано в историю\n", id)
+	return finished, nil
+}
+
+// advance переносит задачу на следующее повторение, минуя даты-исключения.
+// Возвращает true, если повторений больше нет и задачу пора удалить.
+func advance(task *Task, now time.Time) (bool, error) {
+	// Задача завершается, если она не повторяется или это было последнее из xN повторений
+	if task.Repeat == "" || task.Remaining == 1 {
+		return true, nil
+	}
+
+	// Дата передаётся вместе со временем: правила h и min считаются от времени начала
+	start := nextdate.JoinDateTime(task.Date, task.Time)
+	nextDate, err := nextdate.NextDate(clock.In(now, task.TZ), start, task.Repeat, "done", task.ExDates...)
+	if errors.Is(err, nextdate.ErrNoOccurrences) {
+		log.Printf("🔍 [advance] Повторения задачи ID=%d закончились\n", task.ID)
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("ошибка при вычислении следующей даты: %w", err)
+	}
+
+	if nextDate != "" {
+		task.Date, task.Time = nextdate.SplitDateTime(nextDate)
+	}
+	if task.Remaining > 0 {
+		task.Remaining--
+	}
+	return false, nil
+}
+
+// ErrNotRecurring — пропустить можно только повторение повторяющейся задачи
+var ErrNotRecurring = errors.New("задача не повторяется")
+
+// SkipTask добавляет дату date в исключения задачи id. Если пропускается ближайшее
+// повторение, задача в той же транзакции переносится на следующее, как при выполнении,
+// а если повторений больше нет — удаляется.
+func (s *SQLStore) SkipTask(id int64, date string, now time.Time) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	task, err := getTaskByID(tx, id, s.dialect.forUpdate)
+	if err != nil {
+		return err
+	}
+	finished, err := skip(&task, date, now)
+	if err != nil {
+		return err
+	}
+
+	if finished {
+		log.Printf("🔍 [SkipTask] Повторений больше нет. Удаляем задачу ID=%d\n", id)
+		err = deleteTask(tx, id)
+	} else {
+		err = updateTask(tx, task, clock.In(now, task.TZ).Location())
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка при фиксации транзакции: %w", err)
+	}
+	return nil
+}
+
+// skip добавляет дату date в исключения задачи и, если это её ближайшее повторение,
+// переносит задачу на следующее. Возвращает true, если повторений больше нет.
+func skip(task *Task, date string, now time.Time) (bool, error) {
+	if task.Repeat == "" {
+		return false, ErrNotRecurring
+	}
+
+	if !slices.Contains(task.ExDates, date) {
+		task.ExDates = append(slices.Clone(task.ExDates), date)
+		slices.Sort(task.ExDates)
+	}
+
+	finished := false
+	if date == task.Date {
+		log.Printf("🔍 [SkipTask] Пропускается ближайшее повторение задачи ID=%d\n", task.ID)
+		var err error
+		if finished, err = advance(task, now); err != nil {
+			return false, err
+		}
+	}
+	// Исключения раньше даты задачи уже не понадобятся
+	task.ExDates = slices.DeleteFunc(task.ExDates, func(d string) bool { return d < task.Date })
+	return finished, nil
+}
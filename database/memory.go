@@ -0,0 +1,369 @@
+package database
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/naluneotlichno/FP-GO-API/nextdate"
+)
+
+// MemoryStore — хранилище задач в памяти процесса: для тестов и запусков,
+// где данные не нужно сохранять. Все операции выполняются под одной блокировкой,
+// поэтому они атомарны так же, как транзакции SQLStore.
+type MemoryStore struct {
+	mu          sync.Mutex
+	tasks       map[int64]Task
+	lastID      int64
+	completions []Completion
+	lastDoneID  int64
+	undo        map[string]memoryUndo
+}
+
+// memoryUndo — то же, что строка task_undo
+type memoryUndo struct {
+	task         Task
+	completionID int64
+	version      int64 // версия задачи после действия
+	expiresAt    int64 // Unix-время, как в SQL
+}
+
+// NewMemoryStore создаёт пустое хранилище в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tasks: map[int64]Task{}, undo: map[string]memoryUndo{}}
+}
+
+// cloneTask копирует задачу, чтобы вызывающий не менял хранимые даты-исключения
+func cloneTask(t Task) Task {
+	t.ExDates = slices.Clone(t.ExDates)
+	return t
+}
+
+// task возвращает задачу не из корзины
+func (s *MemoryStore) task(id int64) (Task, error) {
+	t, ok := s.tasks[id]
+	if !ok || t.DeletedAt != "" {
+		return Task{}, ErrTask
+	}
+	return cloneTask(t), nil
+}
+
+func (s *MemoryStore) AddTask(t Task) (int64, error) {
+	rule, err := normalizeRepeat(&t)
+	if err != nil {
+		return 0, err
+	}
+	if t.Remaining == 0 {
+		t.Remaining = rule.Count
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastID++
+	t.ID, t.DeletedAt, t.Version = s.lastID, "", 1
+	s.tasks[t.ID] = cloneTask(t)
+	return t.ID, nil
+}
+
+func (s *MemoryStore) GetTaskByID(id int64) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.task(id)
+}
+
+func (s *MemoryStore) FindTasks(f TaskFilter) ([]Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	text := strings.ToLower(f.Text)
+	tasks := []Task{}
+	for _, t := range s.tasks {
+		if t.DeletedAt != "" || f.Date != "" && t.Date != f.Date {
+			continue
+		}
+		if text != "" && !strings.Contains(strings.ToLower(t.Title), text) && !strings.Contains(strings.ToLower(t.Comment), text) {
+			continue
+		}
+		if slices.ContainsFunc(f.Terms, func(term Term) bool { return !term.match(t) }) {
+			continue
+		}
+		if !f.After.IsZero() && !f.After.before(nextdate.JoinDateTime(t.Date, t.Time), t.ID) {
+			continue
+		}
+		tasks = append(tasks, cloneTask(t))
+	}
+	slices.SortFunc(tasks, func(a, b Task) int {
+		return cmp.Or(cmp.Compare(a.Date, b.Date), cmp.Compare(a.Time, b.Time), cmp.Compare(a.ID, b.ID))
+	})
+	if f.Limit > 0 && len(tasks) > f.Limit {
+		tasks = tasks[:f.Limit]
+	}
+	return tasks, nil
+}
+
+func (s *MemoryStore) UpcomingTasks(now time.Time, after Cursor, limit int) ([]Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type upcoming struct {
+		task Task
+		next string
+	}
+	list := []upcoming{}
+	for _, t := range s.tasks {
+		if t.DeletedAt != "" {
+			continue
+		}
+		next, _, err := upcomingDate(t, now)
+		if err != nil {
+			return nil, err
+		}
+		if !after.IsZero() && !after.before(next, t.ID) {
+			continue
+		}
+		list = append(list, upcoming{cloneTask(t), next})
+	}
+	slices.SortFunc(list, func(a, b upcoming) int {
+		return cmp.Or(cmp.Compare(a.next, b.next), cmp.Compare(a.task.ID, b.task.ID))
+	})
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+
+	tasks := make([]Task, len(list))
+	for i, u := range list {
+		tasks[i] = u.task
+		placeUpcoming(&tasks[i], u.next)
+	}
+	return tasks, nil
+}
+
+func (s *MemoryStore) UpdateTask(task Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.update(task)
+}
+
+func (s *MemoryStore) update(task Task) error {
+	if _, err := normalizeRepeat(&task); err != nil {
+		return err
+	}
+	stored, err := s.task(task.ID)
+	if err != nil {
+		return err
+	}
+	if task.Version != 0 && task.Version != stored.Version {
+		return ErrVersion
+	}
+	task.DeletedAt, task.Version = stored.DeletedAt, stored.Version+1
+	s.tasks[task.ID] = cloneTask(task)
+	return nil
+}
+
+func (s *MemoryStore) DeleteTask(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tasks[id]; !ok {
+		return ErrTask
+	}
+	delete(s.tasks, id)
+	return nil
+}
+
+func (s *MemoryStore) DoneTask(id int64, now time.Time, undo Undo, version int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, err := s.task(id)
+	if err != nil {
+		return false, err
+	}
+	if version != 0 && task.Version != version {
+		return false, ErrVersion
+	}
+
+	// Отметка о выполнении и отмена записываются, только если задача уже изменена:
+	// при ошибке в хранилище не должно остаться следов
+	done := cloneTask(task)
+	finished, err := advance(&task, now)
+	if err != nil {
+		return false, err
+	}
+	if finished {
+		delete(s.tasks, id)
+	} else {
+		task.Version = 0
+		if err := s.update(task); err != nil {
+			return false, err
+		}
+	}
+
+	s.lastDoneID++
+	completionID := s.lastDoneID
+	s.completions = append(s.completions, Completion{
+		ID: completionID, TaskID: done.ID, Title: done.Title, Date: done.Date, Time: done.Time, DoneAt: now.Format(DoneAtLayout),
+	})
+	s.saveUndo(undo, done, completionID, done.Version+1)
+	return finished, nil
+}
+
+func (s *MemoryStore) SkipTask(id int64, date string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, err := s.task(id)
+	if err != nil {
+		return err
+	}
+	finished, err := skip(&task, date, now)
+	if err != nil {
+		return err
+	}
+	if finished {
+		delete(s.tasks, id)
+		return nil
+	}
+	task.Version = 0
+	return s.update(task)
+}
+
+// saveUndo — то же, что saveUndo для SQL
+func (s *MemoryStore) saveUndo(undo Undo, task Task, completionID, version int64) {
+	if undo.Token == "" {
+		return
+	}
+	for token, u := range s.undo {
+		if u.task.ID == task.ID || u.expiresAt < undo.IssuedAt.Unix() {
+			delete(s.undo, token)
+		}
+	}
+	s.undo[undo.Token] = memoryUndo{task: cloneTask(task), completionID: completionID, version: version, expiresAt: undo.ExpiresAt.Unix()}
+}
+
+func (s *MemoryStore) UndoTask(token string, now time.Time) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.undo[token]
+	if !ok {
+		return Task{}, ErrUndo
+	}
+	delete(s.undo, token)
+	if now.Unix() > u.expiresAt {
+		return Task{}, ErrUndoExpired
+	}
+	if current, ok := s.tasks[u.task.ID]; ok && u.version != 0 && current.Version != u.version {
+		return Task{}, ErrVersion
+	}
+
+	// Версия продолжает расти и после отмены: старый ETag не должен снова стать верным
+	task := cloneTask(u.task)
+	task.DeletedAt = ""
+	task.Version = max(task.Version, s.tasks[task.ID].Version) + 1
+	s.tasks[task.ID] = task
+
+	if u.completionID > 0 {
+		s.completions = slices.DeleteFunc(s.completions, func(c Completion) bool { return c.ID == u.completionID })
+	}
+	return cloneTask(task), nil
+}
+
+func (s *MemoryStore) TrashTask(id int64, now time.Time, undo Undo, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, err := s.task(id)
+	if err != nil {
+		return err
+	}
+	if version != 0 && task.Version != version {
+		return ErrVersion
+	}
+	s.saveUndo(undo, task, 0, task.Version+1)
+	task.DeletedAt = now.Format(DoneAtLayout)
+	task.Version++
+	s.tasks[id] = task
+	return nil
+}
+
+func (s *MemoryStore) GetTrash() ([]Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks := []Task{}
+	for _, t := range s.tasks {
+		if t.DeletedAt != "" {
+			tasks = append(tasks, cloneTask(t))
+		}
+	}
+	slices.SortFunc(tasks, func(a, b Task) int {
+		return cmp.Or(cmp.Compare(b.DeletedAt, a.DeletedAt), cmp.Compare(b.ID, a.ID))
+	})
+	return tasks, nil
+}
+
+func (s *MemoryStore) RestoreTask(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[id]
+	if !ok || task.DeletedAt == "" {
+		return ErrTask
+	}
+	task.DeletedAt = ""
+	task.Version++
+	s.tasks[id] = task
+	return nil
+}
+
+func (s *MemoryStore) PurgeTrash(before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for id, t := range s.tasks {
+		if t.DeletedAt != "" && t.DeletedAt < before.Format(DoneAtLayout) {
+			delete(s.tasks, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryStore) GetTaskHistory(taskID int64) ([]Completion, error) {
+	return s.completionsWhere(func(c Completion) bool { return c.TaskID == taskID }), nil
+}
+
+func (s *MemoryStore) GetCompletions(from, to string) ([]Completion, error) {
+	end := ""
+	if to != "" {
+		var err error
+		if end, err = dayAfter(to); err != nil {
+			return nil, err
+		}
+	}
+	return s.completionsWhere(func(c Completion) bool {
+		return (from == "" || c.DoneAt >= from) && (end == "" || c.DoneAt < end)
+	}), nil
+}
+
+// completionsWhere возвращает подходящие выполнения по возрастанию done_at, затем ID
+func (s *MemoryStore) completionsWhere(match func(Completion) bool) []Completion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	completions := []Completion{}
+	for _, c := range s.completions {
+		if match(c) {
+			completions = append(completions, c)
+		}
+	}
+	slices.SortStableFunc(completions, func(a, b Completion) int { return cmp.Compare(a.DoneAt, b.DoneAt) })
+	return completions
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,330 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles — миграции схемы, у каждой базы свои: migrations/<база>/NNNN_name.up.sql
+// и парная NNNN_name.down.sql
+//
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+// Migration — одна версия схемы базы
+type Migration struct {
+	Version int
+	Name    string
+	Up      string // SQL для перехода на эту версию
+	Down    string // SQL для отката к предыдущей
+}
+
+// MigrationState — миграция и момент её применения; пустой AppliedAt — ещё не применена
+type MigrationState struct {
+	Migration
+	AppliedAt string
+}
+
+const createMigrationsSQL = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`
+
+// migrationData — шаги миграций, которые нельзя одинаково записать на SQL всех баз:
+// они выполняются сразу после SQL миграции с тем же именем, под той же блокировкой
+var migrationData = map[string]func(c execer) error{
+	"add_lower_text": fillLowerText,
+}
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migrations возвращает все встроенные миграции базы хранилища по возрастанию версии
+func (s *SQLStore) Migrations() ([]Migration, error) {
+	dir := s.dialect.migrations
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		match := migrationFileName.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("некорректное имя файла миграции: %s", e.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		body, err := migrationFiles.ReadFile(dir + "/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("у миграции %04d разные имена: %s и %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("пропущена миграция %04d", i+1)
+		}
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("у миграции %04d %s нет up или down", m.Version, m.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// withMigrationLock выполняет f под блокировкой миграций базы (см. dialect.migrationLock):
+// два процесса, запущенные одновременно, применяют миграции по очереди,
+// и второй видит уже обновлённую схему.
+func (s *SQLStore) withMigrationLock(f func(c execer, applied map[int]string) error) error {
+	c, release, err := s.dialect.migrationLock(s.db)
+	if err != nil {
+		return err
+	}
+
+	err = s.migrate(s.dialect.wrap(c), f)
+	if releaseErr := release(err == nil); err == nil {
+		err = releaseErr
+	}
+	return err
+}
+
+func (s *SQLStore) migrate(c execer, f func(c execer, applied map[int]string) error) error {
+	tracked, err := s.dialect.tableExists(c, "schema_migrations")
+	if err != nil {
+		return err
+	}
+	if _, err := c.Exec(createMigrationsSQL); err != nil {
+		return fmt.Errorf("ошибка при создании таблицы schema_migrations: %w", err)
+	}
+	if !tracked && s.dialect.adoptLegacy {
+		if err := s.adoptLegacySchema(c); err != nil {
+			return err
+		}
+	}
+
+	applied, err := appliedMigrations(c)
+	if err != nil {
+		return err
+	}
+	return f(c, applied)
+}
+
+var statementEnd = regexp.MustCompile(`;[ \t]*(\r?\n|$)`)
+
+// execScript выполняет SQL миграции по одному оператору: не все драйверы
+// принимают несколько операторов в одном запросе
+func execScript(c execer, script string) error {
+	for _, stmt := range statementEnd.Split(script, -1) {
+		if strings.TrimSpace(stmt) == "" {
+			continue
+		}
+		if _, err := c.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateUp применяет все ещё не применённые миграции и возвращает их
+func (s *SQLStore) MigrateUp() ([]Migration, error) {
+	migrations, err := s.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = s.withMigrationLock(func(c execer, applied map[int]string) error {
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := execScript(c, m.Up); err != nil {
+				return fmt.Errorf("миграция %04d %s: %w", m.Version, m.Name, err)
+			}
+			if fill, ok := migrationData[m.Name]; ok {
+				if err := fill(c); err != nil {
+					return fmt.Errorf("миграция %04d %s: %w", m.Version, m.Name, err)
+				}
+			}
+			if err := recordMigration(c, m); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return done, nil
+}
+
+// MigrateDown откатывает последнюю применённую миграцию.
+// Если откатывать нечего, возвращает false.
+func (s *SQLStore) MigrateDown() (Migration, bool, error) {
+	migrations, err := s.Migrations()
+	if err != nil {
+		return Migration{}, false, err
+	}
+
+	var (
+		undone Migration
+		found  bool
+	)
+	err = s.withMigrationLock(func(c execer, applied map[int]string) error {
+		for i := len(migrations) - 1; i >= 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if err := execScript(c, m.Down); err != nil {
+				return fmt.Errorf("откат миграции %04d %s: %w", m.Version, m.Name, err)
+			}
+			if _, err := c.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+				return fmt.Errorf("ошибка при удалении записи о миграции: %w", err)
+			}
+			undone, found = m, true
+			return nil
+		}
+		return nil
+	})
+	return undone, found, err
+}
+
+// MigrationStatus возвращает все миграции с отметкой, применены ли они.
+// База только читается: если schema_migrations ещё нет, ни одна миграция не считается
+// применённой, а миграции старой версии без неё отметит уже MigrateUp.
+func (s *SQLStore) MigrationStatus() ([]MigrationState, error) {
+	migrations, err := s.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	c := s.conn()
+	tracked, err := s.dialect.tableExists(c, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	applied := map[int]string{}
+	if tracked {
+		if applied, err = appliedMigrations(c); err != nil {
+			return nil, err
+		}
+	}
+
+	var states []MigrationState
+	for _, m := range migrations {
+		states = append(states, MigrationState{Migration: m, AppliedAt: applied[m.Version]})
+	}
+	return states, nil
+}
+
+func appliedMigrations(c execer) (map[int]string, error) {
+	rows, err := c.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]string{}
+	for rows.Next() {
+		var (
+			version int
+			at      string
+		)
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+func recordMigration(c execer, m Migration) error {
+	_, err := c.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		m.Version, m.Name, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("ошибка при записи миграции %04d: %w", m.Version, err)
+	}
+	return nil
+}
+
+var (
+	createsTable = regexp.MustCompile(`(?i)CREATE TABLE IF NOT EXISTS (\w+)`)
+	addsColumn   = regexp.MustCompile(`(?i)ALTER TABLE (\w+) ADD COLUMN (\w+)`)
+)
+
+// adoptLegacySchema отмечает применёнными миграции, которые уже выполнила версия
+// без schema_migrations: она создавала таблицы и досоздавала колонки сама.
+// Миграция считается применённой, если все её таблицы и колонки уже есть.
+func (s *SQLStore) adoptLegacySchema(c execer) error {
+	legacy, err := s.dialect.tableExists(c, "scheduler")
+	if err != nil || !legacy {
+		return err
+	}
+
+	migrations, err := s.Migrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		present, err := s.schemaPresent(c, m.Up)
+		if err != nil {
+			return err
+		}
+		if !present {
+			continue
+		}
+		if err := recordMigration(c, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaPresent проверяет, что все таблицы и колонки, которые создаёт up, уже существуют
+func (s *SQLStore) schemaPresent(c execer, up string) (bool, error) {
+	tables := createsTable.FindAllStringSubmatch(up, -1)
+	columns := addsColumn.FindAllStringSubmatch(up, -1)
+	if len(tables) == 0 && len(columns) == 0 {
+		return false, nil
+	}
+
+	for _, t := range tables {
+		if ok, err := s.dialect.tableExists(c, t[1]); err != nil || !ok {
+			return false, err
+		}
+	}
+	for _, col := range columns {
+		if ok, err := hasColumn(c, col[1], col[2]); err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
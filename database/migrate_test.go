@@ -0,0 +1,205 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openSQLite открывает базу SQLite по пути path и применяет миграции
+func openSQLite(t *testing.T, path string) *SQLStore {
+	t.Helper()
+	s, err := Open(DriverSQLite, path)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s.(*SQLStore)
+}
+
+// requireApplied проверяет, что применены все миграции
+func requireApplied(t *testing.T, s *SQLStore) {
+	t.Helper()
+	states, err := s.MigrationStatus()
+	require.NoError(t, err)
+	migrations, err := s.Migrations()
+	require.NoError(t, err)
+	require.Len(t, states, len(migrations))
+	for _, s := range states {
+		assert.NotEmpty(t, s.AppliedAt, "%04d %s", s.Version, s.Name)
+	}
+}
+
+func TestMigrateOriginalDatabase(t *testing.T) {
+	// База самой первой версии: только id, date, title, comment, repeat и без schema_migrations.
+	// Схема строится заново, а не копируется из scheduler.db в корне: тот файл меняют другие тесты
+	path := filepath.Join(t.TempDir(), "scheduler.db")
+	old, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = old.Exec(`
+		CREATE TABLE scheduler (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			date TEXT NOT NULL,
+			title TEXT NOT NULL,
+			comment TEXT,
+			repeat TEXT(128)
+		);
+		CREATE INDEX idx_date ON scheduler(date);
+		CREATE INDEX idx_title ON scheduler(title);
+		INSERT INTO scheduler (date, title, comment, repeat) VALUES ('20240101', 'Старая задача', '', 'd 7');
+	`)
+	require.NoError(t, err)
+	require.NoError(t, old.Close())
+	const id, title = int64(1), "Старая задача"
+
+	s := openSQLite(t, path)
+	requireApplied(t, s)
+
+	// Данные на месте, новые колонки получили значения по умолчанию
+	task, err := s.GetTaskByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, title, task.Title)
+	assert.Equal(t, int64(1), task.Version)
+	assert.Empty(t, task.ExDates)
+
+	// Колонки для поиска без учёта регистра заполнены и у старых задач
+	found, err := s.FindTasks(TaskFilter{Text: strings.ToUpper(title)})
+	require.NoError(t, err)
+	require.NotEmpty(t, found)
+	assert.Equal(t, id, found[0].ID)
+
+	// Повторный запуск ничего не применяет
+	applied, err := s.MigrateUp()
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+}
+
+func TestMigrateLegacyColumns(t *testing.T) {
+	// База версии, которая досоздавала колонки сама и не вела schema_migrations
+	path := filepath.Join(t.TempDir(), "scheduler.db")
+	old, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = old.Exec(`
+		CREATE TABLE scheduler (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			date TEXT NOT NULL,
+			title TEXT NOT NULL,
+			comment TEXT,
+			repeat TEXT(128),
+			remaining INTEGER NOT NULL DEFAULT 0,
+			time TEXT NOT NULL DEFAULT '',
+			duration INTEGER NOT NULL DEFAULT 0,
+			tz TEXT NOT NULL DEFAULT ''
+		);
+		INSERT INTO scheduler (date, title, comment, repeat, remaining, time) VALUES ('20240101', 'Задача', '', 'd 1 x3', 2, '09:30');
+	`)
+	require.NoError(t, err)
+	require.NoError(t, old.Close())
+
+	s := openSQLite(t, path)
+	requireApplied(t, s)
+
+	task, err := s.GetTaskByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, task.Remaining)
+	assert.Equal(t, "09:30", task.Time)
+
+	// Место в списке предстоящих заполняется для уже существующих задач
+	var next string
+	require.NoError(t, s.db.QueryRow("SELECT next_date FROM scheduler WHERE id = 1").Scan(&next))
+	assert.Equal(t, "20240101 09:30", next)
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	testMigrateDownAndUp(t, DriverSQLite, filepath.Join(t.TempDir(), "scheduler.db"))
+}
+
+// TestMySQLMigrateDownAndUp откатывает и заново применяет все миграции MySQL,
+// поэтому базу из TODO_TEST_MYSQL_DSN нужно отвести под тесты
+func TestMySQLMigrateDownAndUp(t *testing.T) {
+	testMigrateDownAndUp(t, DriverMySQL, externalDSN(t, "TODO_TEST_MYSQL_DSN"))
+}
+
+// TestPostgresMigrateDownAndUp — то же для PostgreSQL и его pg_advisory_xact_lock
+func TestPostgresMigrateDownAndUp(t *testing.T) {
+	testMigrateDownAndUp(t, DriverPostgres, externalDSN(t, "TODO_TEST_POSTGRES_DSN"))
+}
+
+func testMigrateDownAndUp(t *testing.T, driver, dsn string) {
+	s := openStore(t, driver, dsn).(*SQLStore)
+	migrations, err := s.Migrations()
+	require.NoError(t, err)
+
+	// Откат идёт от последней миграции к первой
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m, ok, err := s.MigrateDown()
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, migrations[i].Version, m.Version)
+	}
+	_, ok, err := s.MigrateDown()
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	for _, table := range []string{"scheduler", "task_completions", "task_undo"} {
+		exists, err := s.dialect.tableExists(s.db, table)
+		require.NoError(t, err)
+		assert.False(t, exists, table)
+	}
+
+	// Одновременные запуски на пустой базе ждут друг друга на блокировке миграций,
+	// и каждая миграция применяется один раз
+	errs := make([]error, 4)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store, err := Open(driver, dsn)
+			if err == nil {
+				store.Close()
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	requireApplied(t, s)
+
+	applied, err := s.MigrateUp()
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+}
+
+func TestMigrationStatusReadOnly(t *testing.T) {
+	// Старая база без schema_migrations: status ничего в неё не пишет
+	path := filepath.Join(t.TempDir(), "scheduler.db")
+	old, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = old.Exec(`CREATE TABLE scheduler (id INTEGER PRIMARY KEY AUTOINCREMENT, date TEXT NOT NULL, title TEXT NOT NULL, comment TEXT, repeat TEXT(128))`)
+	require.NoError(t, err)
+	require.NoError(t, old.Close())
+
+	s, err := OpenSQL(DriverSQLite, path)
+	require.NoError(t, err)
+	defer s.Close()
+	states, err := s.MigrationStatus()
+	require.NoError(t, err)
+	require.NotEmpty(t, states)
+	for _, st := range states {
+		assert.Empty(t, st.AppliedAt, "%04d %s", st.Version, st.Name)
+	}
+	tracked, err := sqliteDialect.tableExists(s.db, "schema_migrations")
+	require.NoError(t, err)
+	assert.False(t, tracked)
+
+	// После up status показывает применённые миграции
+	_, err = s.MigrateUp()
+	require.NoError(t, err)
+	requireApplied(t, s)
+}
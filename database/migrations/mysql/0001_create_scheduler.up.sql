@@ -0,0 +1,18 @@
+-- Задачи в том виде, к которому пришли миграции SQLite 0001–0009.
+-- repeat — зарезервированное слово MySQL, поэтому колонка в обратных кавычках
+CREATE TABLE IF NOT EXISTS scheduler (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	date VARCHAR(8) NOT NULL,
+	title TEXT NOT NULL,
+	comment TEXT,
+	`repeat` VARCHAR(128) NOT NULL DEFAULT '',
+	remaining INT NOT NULL DEFAULT 0,
+	time VARCHAR(5) NOT NULL DEFAULT '',
+	duration INT NOT NULL DEFAULT 0,
+	tz VARCHAR(64) NOT NULL DEFAULT '',
+	exdates VARCHAR(2048) NOT NULL DEFAULT '',
+	deleted_at VARCHAR(19) NOT NULL DEFAULT '',
+	version BIGINT NOT NULL DEFAULT 1,
+	INDEX idx_date (date),
+	INDEX idx_title (title(191))
+) DEFAULT CHARSET = utf8mb4;
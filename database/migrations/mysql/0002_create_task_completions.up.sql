@@ -0,0 +1,11 @@
+-- История выполнения: задача при выполнении удаляется или переезжает, а запись остаётся
+CREATE TABLE IF NOT EXISTS task_completions (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	task_id BIGINT NOT NULL,
+	title TEXT NOT NULL,
+	date VARCHAR(8) NOT NULL,
+	time VARCHAR(5) NOT NULL DEFAULT '',
+	done_at VARCHAR(19) NOT NULL,
+	INDEX idx_completions_task (task_id),
+	INDEX idx_completions_done_at (done_at)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,9 @@
+-- Состояние задачи до выполнения или удаления, пока его можно вернуть
+CREATE TABLE IF NOT EXISTS task_undo (
+	token VARCHAR(64) PRIMARY KEY,
+	task_id BIGINT NOT NULL,
+	task TEXT NOT NULL,
+	completion_id BIGINT NOT NULL DEFAULT 0,
+	expires_at BIGINT NOT NULL,
+	INDEX idx_undo_task (task_id)
+) DEFAULT CHARSET = utf8mb4;
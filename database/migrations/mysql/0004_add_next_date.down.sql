@@ -0,0 +1,2 @@
+DROP INDEX idx_next_date ON scheduler;
+ALTER TABLE scheduler DROP COLUMN next_date;
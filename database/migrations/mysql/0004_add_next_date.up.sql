@@ -0,0 +1,5 @@
+-- Место задачи в списке предстоящих: дата YYYYMMDD, у задач со временем — «YYYYMMDD HH:MM».
+-- У просроченной повторяющейся задачи оно уходит вперёд на ближайшее повторение
+ALTER TABLE scheduler ADD COLUMN next_date VARCHAR(14) NOT NULL DEFAULT '';
+UPDATE scheduler SET next_date = CASE WHEN time = '' THEN date ELSE CONCAT(date, ' ', time) END;
+CREATE INDEX idx_next_date ON scheduler (next_date, id);
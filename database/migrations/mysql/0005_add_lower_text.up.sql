@@ -0,0 +1,6 @@
+-- Заголовок и комментарий в нижнем регистре для поиска без учёта регистра, одинакового
+-- во всех базах. Сравнение побайтовое (utf8mb4_bin): обычная сортировка MySQL считает
+-- одинаковыми и разные буквы вроде «е» и «ё». Значения записывает хранилище через
+-- strings.ToLower, у уже добавленных задач — сразу после этой миграции (fillLowerText)
+ALTER TABLE scheduler ADD COLUMN title_lower TEXT COLLATE utf8mb4_bin NOT NULL DEFAULT ('');
+ALTER TABLE scheduler ADD COLUMN comment_lower TEXT COLLATE utf8mb4_bin NOT NULL DEFAULT ('');
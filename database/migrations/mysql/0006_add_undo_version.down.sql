@@ -0,0 +1 @@
+ALTER TABLE task_undo DROP COLUMN version;
@@ -0,0 +1,2 @@
+DROP INDEX idx_refresh_at ON scheduler;
+ALTER TABLE scheduler DROP COLUMN refresh_at;
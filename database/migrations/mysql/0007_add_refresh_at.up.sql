@@ -0,0 +1,7 @@
+-- Когда место повторяющейся задачи в списке предстоящих (next_date) устареет: момент в UTC
+-- «YYYYMMDD HH:MM:SS», полночь после даты next_date по часам задачи. Пустая строка — не устареет:
+-- задача не повторяется или её повторения закончились. Места уже добавленных повторяющихся задач
+-- пересчитываются при первом запросе списка
+ALTER TABLE scheduler ADD COLUMN refresh_at VARCHAR(19) NOT NULL DEFAULT '';
+UPDATE scheduler SET refresh_at = '0' WHERE `repeat` != '';
+CREATE INDEX idx_refresh_at ON scheduler (refresh_at);
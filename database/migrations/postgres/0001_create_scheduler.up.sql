@@ -0,0 +1,17 @@
+-- Задачи в том виде, к которому пришли миграции SQLite 0001–0009
+CREATE TABLE IF NOT EXISTS scheduler (
+	id BIGSERIAL PRIMARY KEY,
+	date TEXT NOT NULL,
+	title TEXT NOT NULL,
+	comment TEXT,
+	repeat TEXT NOT NULL DEFAULT '',
+	remaining INTEGER NOT NULL DEFAULT 0,
+	time TEXT NOT NULL DEFAULT '',
+	duration INTEGER NOT NULL DEFAULT 0,
+	tz TEXT NOT NULL DEFAULT '',
+	exdates TEXT NOT NULL DEFAULT '',
+	deleted_at TEXT NOT NULL DEFAULT '',
+	version BIGINT NOT NULL DEFAULT 1
+);
+CREATE INDEX IF NOT EXISTS idx_date ON scheduler(date);
+CREATE INDEX IF NOT EXISTS idx_title ON scheduler(title);
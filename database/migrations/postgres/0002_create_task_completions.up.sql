@@ -0,0 +1,11 @@
+-- История выполнения: задача при выполнении удаляется или переезжает, а запись остаётся
+CREATE TABLE IF NOT EXISTS task_completions (
+	id BIGSERIAL PRIMARY KEY,
+	task_id BIGINT NOT NULL,
+	title TEXT NOT NULL,
+	date TEXT NOT NULL,
+	time TEXT NOT NULL DEFAULT '',
+	done_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_completions_task ON task_completions(task_id);
+CREATE INDEX IF NOT EXISTS idx_completions_done_at ON task_completions(done_at);
@@ -0,0 +1,9 @@
+-- Состояние задачи до выполнения или удаления, пока его можно вернуть
+CREATE TABLE IF NOT EXISTS task_undo (
+	token TEXT PRIMARY KEY,
+	task_id BIGINT NOT NULL,
+	task TEXT NOT NULL,
+	completion_id BIGINT NOT NULL DEFAULT 0,
+	expires_at BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_undo_task ON task_undo(task_id);
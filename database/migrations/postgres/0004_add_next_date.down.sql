@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_next_date;
+ALTER TABLE scheduler DROP COLUMN next_date;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_refresh_at;
+ALTER TABLE scheduler DROP COLUMN refresh_at;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS scheduler (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	date TEXT NOT NULL,
+	title TEXT NOT NULL,
+	comment TEXT,
+	repeat TEXT(128)
+);
+CREATE INDEX IF NOT EXISTS idx_date ON scheduler(date);
+CREATE INDEX IF NOT EXISTS idx_title ON scheduler(title);
@@ -0,0 +1,2 @@
+-- Сколько повторений осталось, включая текущее; 0 — без ограничения
+ALTER TABLE scheduler ADD COLUMN remaining INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE scheduler DROP COLUMN duration;
+ALTER TABLE scheduler DROP COLUMN time;
@@ -0,0 +1,3 @@
+-- Время начала HH:MM и длительность в минутах
+ALTER TABLE scheduler ADD COLUMN time TEXT NOT NULL DEFAULT '';
+ALTER TABLE scheduler ADD COLUMN duration INTEGER NOT NULL DEFAULT 0;
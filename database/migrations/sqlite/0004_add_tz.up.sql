@@ -0,0 +1,2 @@
+-- Часовой пояс IANA, в котором считается «сегодня»; пустой — пояс сервера
+ALTER TABLE scheduler ADD COLUMN tz TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Даты-исключения YYYYMMDD через запятую
+ALTER TABLE scheduler ADD COLUMN exdates TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Задачи из корзины без этой колонки не отличить от обычных
+DELETE FROM scheduler WHERE deleted_at != '';
+ALTER TABLE scheduler DROP COLUMN deleted_at;
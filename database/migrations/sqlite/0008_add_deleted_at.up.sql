@@ -0,0 +1,2 @@
+-- Когда задачу отправили в корзину; пустое — не в корзине
+ALTER TABLE scheduler ADD COLUMN deleted_at TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Версия задачи для ETag и If-Match, растёт при каждом изменении
+ALTER TABLE scheduler ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
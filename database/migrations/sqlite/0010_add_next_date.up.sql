@@ -0,0 +1,5 @@
+-- Место задачи в списке предстоящих: дата YYYYMMDD, у задач со временем — «YYYYMMDD HH:MM».
+-- У просроченной повторяющейся задачи оно уходит вперёд на ближайшее повторение
+ALTER TABLE scheduler ADD COLUMN next_date TEXT NOT NULL DEFAULT '';
+UPDATE scheduler SET next_date = CASE WHEN time = '' THEN date ELSE date || ' ' || time END;
+CREATE INDEX IF NOT EXISTS idx_next_date ON scheduler(next_date, id);
@@ -0,0 +1,2 @@
+ALTER TABLE scheduler DROP COLUMN comment_lower;
+ALTER TABLE scheduler DROP COLUMN title_lower;
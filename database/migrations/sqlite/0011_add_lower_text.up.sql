@@ -0,0 +1,5 @@
+-- Заголовок и комментарий в нижнем регистре для поиска без учёта регистра: LOWER и LIKE
+-- в SQLite понимают регистр только латинских букв. Значения записывает хранилище через
+-- strings.ToLower, у уже добавленных задач — сразу после этой миграции (fillLowerText)
+ALTER TABLE scheduler ADD COLUMN title_lower TEXT NOT NULL DEFAULT '';
+ALTER TABLE scheduler ADD COLUMN comment_lower TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Версия задачи сразу после выполнения или удаления: если задачу с тех пор изменили,
+-- отмена не перезаписывает это изменение. 0 — версия не запомнена, проверки нет
+ALTER TABLE task_undo ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
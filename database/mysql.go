@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlMigrationLock — имя блокировки GET_LOCK, под которой применяются миграции
+const mysqlMigrationLock = "gopad_schema_migrations"
+
+// mysqlDialect — MySQL 8; источник данных — DSN go-sql-driver/mysql,
+// например user:password@tcp(localhost:3306)/gopad
+var mysqlDialect = &dialect{
+	driver:     "mysql",
+	migrations: "migrations/mysql",
+	dsn:        func(dsn string) string { return dsn },
+	describe: func(dsn string) string {
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return "некорректный DSN"
+		}
+		return cfg.Addr + "/" + cfg.DBName
+	},
+	// В MySQL идентификаторы берутся в обратные кавычки: repeat — зарезервированное слово
+	rebind:    func(query string) string { return strings.ReplaceAll(query, `"`, "`") },
+	forUpdate: " FOR UPDATE",
+	// DDL в MySQL не откатывается вместе с транзакцией, поэтому миграции выполняются
+	// на одном соединении под именованной блокировкой GET_LOCK
+	migrationLock: func(db *sql.DB) (execer, func(bool) error, error) {
+		ctx := context.Background()
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ошибка при получении соединения: %w", err)
+		}
+
+		var locked sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 60)", mysqlMigrationLock).Scan(&locked); err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("ошибка при взятии блокировки миграций: %w", err)
+		}
+		if locked.Int64 != 1 {
+			conn.Close()
+			return nil, nil, fmt.Errorf("блокировку миграций держит другой процесс дольше минуты")
+		}
+		return connExecer{conn}, func(bool) error {
+			defer conn.Close()
+			_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", mysqlMigrationLock)
+			return err
+		}, nil
+	},
+	tableExists: func(c execer, table string) (bool, error) {
+		var n int
+		err := c.QueryRow("SELECT count(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?", table).Scan(&n)
+		if err != nil {
+			return false, fmt.Errorf("ошибка при проверке таблицы %s: %w", table, err)
+		}
+		return n > 0, nil
+	},
+}
+
+// connExecer выполняет запросы на одном выделенном соединении
+type connExecer struct {
+	conn *sql.Conn
+}
+
+func (c connExecer) Exec(query string, args ...any) (sql.Result, error) {
+	return c.conn.ExecContext(context.Background(), query, args...)
+}
+
+func (c connExecer) Query(query string, args ...any) (*sql.Rows, error) {
+	return c.conn.QueryContext(context.Background(), query, args...)
+}
+
+func (c connExecer) QueryRow(query string, args ...any) *sql.Row {
+	return c.conn.QueryRowContext(context.Background(), query, args...)
+}
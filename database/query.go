@@ -0,0 +1,256 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrQuery — в поисковом запросе синтаксическая ошибка; текст ошибки объясняет, какая
+var ErrQuery = errors.New("ошибка в поисковом запросе")
+
+// maxQueryTerms — больше условий в одном поисковом запросе не принимается
+const maxQueryTerms = 16
+
+// Term — условие поискового запроса. Field — поле задачи: пустое (заголовок или
+// комментарий), title, comment, repeat, date, from или to; для дат Value — YYYYMMDD.
+// Not означает, что задача должна условию не соответствовать.
+type Term struct {
+	Field string
+	Value string
+	Not   bool
+}
+
+// queryFields — поля, которые можно указать в запросе как «поле:значение»
+var queryFields = []string{"from", "to", "date", "title", "comment", "repeat"}
+
+// ParseQuery разбирает поисковый запрос. Условия разделяются пробелами, и задача
+// должна подходить под все:
+//
+//	отчёт                          подстрока заголовка или комментария
+//	"годовой отчёт"                фраза с пробелами
+//	01.03.2026                     задачи на эту дату, то же, что date:01.03.2026
+//	from:01.03.2026 to:31.03.2026  задачи на даты в этих границах включительно
+//	title:отчёт, comment:срочно    подстрока только заголовка или только комментария
+//	repeat:none, repeat:any        неповторяющиеся или повторяющиеся задачи
+//	repeat:w, repeat:"d 7"         задачи с правилом повторения этого вида или с этим правилом
+//	-title:черновик                минус перед условием: задача под него не подходит
+//
+// Слово с двоеточием, в котором до двоеточия не имя поля, — обычный текст.
+// Ошибки синтаксиса оборачивают ErrQuery.
+func ParseQuery(s string) ([]Term, error) {
+	var (
+		terms    []Term
+		from, to string
+		rest     = []rune(s)
+	)
+	for {
+		for len(rest) > 0 && unicode.IsSpace(rest[0]) {
+			rest = rest[1:]
+		}
+		if len(rest) == 0 {
+			break
+		}
+
+		var t Term
+		if rest[0] == '-' {
+			t.Not = true
+			if rest = rest[1:]; len(rest) == 0 || unicode.IsSpace(rest[0]) {
+				return nil, fmt.Errorf("%w: после «-» нет условия", ErrQuery)
+			}
+		}
+		field, value, quoted, tail, err := nextTerm(rest)
+		if err != nil {
+			return nil, err
+		}
+		rest = tail
+		if t.Field, t.Value, err = parseTerm(field, value, quoted); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case t.Field == "from" && !t.Not:
+			from = t.Value
+		case t.Field == "to" && !t.Not:
+			to = t.Value
+		}
+		if terms = append(terms, t); len(terms) > maxQueryTerms {
+			return nil, fmt.Errorf("%w: больше %d условий", ErrQuery, maxQueryTerms)
+		}
+	}
+
+	if from != "" && to != "" && to < from {
+		return nil, fmt.Errorf("%w: дата to раньше даты from", ErrQuery)
+	}
+	return terms, nil
+}
+
+// nextTerm отделяет от начала s одно условие: поле (если есть), значение и остаток строки
+func nextTerm(s []rune) (field, value string, quoted bool, rest []rune, err error) {
+	// Поле — известное имя перед двоеточием; «18:00», «re:встреча» и ссылки остаются просто текстом
+	if i := slices.Index(s, ':'); i > 0 {
+		if name := strings.ToLower(string(s[:i])); slices.Contains(queryFields, name) {
+			field, s = name, s[i+1:]
+		}
+	}
+
+	if len(s) > 0 && s[0] == '"' {
+		end := slices.Index(s[1:], '"')
+		if end < 0 {
+			return "", "", false, nil, fmt.Errorf("%w: не закрыта кавычка", ErrQuery)
+		}
+		value, rest = string(s[1:end+1]), s[end+2:]
+		if len(rest) > 0 && !unicode.IsSpace(rest[0]) {
+			return "", "", false, nil, fmt.Errorf("%w: после закрывающей кавычки нужен пробел", ErrQuery)
+		}
+		if strings.TrimSpace(value) == "" {
+			return "", "", false, nil, fmt.Errorf("%w: пустая фраза в кавычках", ErrQuery)
+		}
+		return field, value, true, rest, nil
+	}
+
+	end := slices.IndexFunc(s, unicode.IsSpace)
+	if end < 0 {
+		end = len(s)
+	}
+	value, rest = string(s[:end]), s[end:]
+	if value == "" {
+		return "", "", false, nil, fmt.Errorf("%w: у поля %s нет значения", ErrQuery, field)
+	}
+	return field, value, false, rest, nil
+}
+
+// parseTerm проверяет значение поля и приводит его к виду, в котором оно сравнивается с задачей
+func parseTerm(field, value string, quoted bool) (string, string, error) {
+	switch field {
+	case "from", "to", "date":
+		date, err := time.Parse("02.01.2006", value)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: некорректная дата «%s» у поля %s, ожидается ДД.ММ.ГГГГ", ErrQuery, value, field)
+		}
+		return field, date.Format("20060102"), nil
+	case "repeat":
+		return field, strings.ToLower(strings.TrimSpace(value)), nil
+	case "":
+		// Слово в виде даты ищет задачи на эту дату, как и раньше; фраза в кавычках — всегда текст
+		if date, err := time.Parse("02.01.2006", value); err == nil && !quoted {
+			return "date", date.Format("20060102"), nil
+		}
+	}
+	return field, value, nil
+}
+
+// sql возвращает условие WHERE для t с параметрами. Текст ищется в колонках
+// title_lower и comment_lower: без учёта регистра так же, как match, в любой базе.
+func (t Term) sql() (string, []any) {
+	var (
+		cond    string
+		args    []any
+		pattern = likePattern(t.Value)
+	)
+	switch t.Field {
+	case "title":
+		cond, args = "title_lower LIKE ? ESCAPE '!'", []any{pattern}
+	case "comment":
+		cond, args = "comment_lower LIKE ? ESCAPE '!'", []any{pattern}
+	case "repeat":
+		switch t.Value {
+		case "none":
+			cond = `"repeat" = ''`
+		case "any":
+			cond = `"repeat" != ''`
+		default:
+			cond, args = `("repeat" = ? OR "repeat" LIKE ?)`, []any{t.Value, t.Value + " %"}
+		}
+	case "date":
+		cond, args = "date = ?", []any{t.Value}
+	case "from":
+		cond, args = "date >= ?", []any{t.Value}
+	case "to":
+		cond, args = "date <= ?", []any{t.Value}
+	default:
+		cond, args = "(title_lower LIKE ? ESCAPE '!' OR comment_lower LIKE ? ESCAPE '!')", []any{pattern, pattern}
+	}
+	if t.Not {
+		cond = "NOT (" + cond + ")"
+	}
+	return cond, args
+}
+
+// likeEscaper экранирует в шаблоне LIKE символы % и _: в запросе они означают сами себя
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// likePattern — шаблон LIKE для поиска подстроки s в колонках *_lower
+func likePattern(s string) string {
+	return "%" + likeEscaper.Replace(strings.ToLower(s)) + "%"
+}
+
+// fillLowerText заполняет title_lower и comment_lower у задач, добавленных
+// до появления этих колонок
+func fillLowerText(c execer) error {
+	type text struct {
+		id             int64
+		title, comment string
+	}
+	// Строки дочитываются до обновлений: соединение миграций у MySQL одно
+	var texts []text
+	err := func() error {
+		rows, err := c.Query("SELECT id, title, COALESCE(comment, '') FROM scheduler")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var t text
+			if err := rows.Scan(&t.id, &t.title, &t.comment); err != nil {
+				return err
+			}
+			texts = append(texts, t)
+		}
+		return rows.Err()
+	}()
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении задач: %w", err)
+	}
+
+	for _, t := range texts {
+		if _, err := c.Exec("UPDATE scheduler SET title_lower = ?, comment_lower = ? WHERE id = ?", strings.ToLower(t.title), strings.ToLower(t.comment), t.id); err != nil {
+			return fmt.Errorf("ошибка при обновлении задачи ID %d: %w", t.id, err)
+		}
+	}
+	return nil
+}
+
+// match проверяет задачу так же, как условие sql, без учёта регистра
+func (t Term) match(task Task) bool {
+	contains := func(s string) bool { return strings.Contains(strings.ToLower(s), strings.ToLower(t.Value)) }
+
+	var ok bool
+	switch t.Field {
+	case "title":
+		ok = contains(task.Title)
+	case "comment":
+		ok = contains(task.Comment)
+	case "repeat":
+		switch t.Value {
+		case "none":
+			ok = task.Repeat == ""
+		case "any":
+			ok = task.Repeat != ""
+		default:
+			ok = task.Repeat == t.Value || strings.HasPrefix(task.Repeat, t.Value+" ")
+		}
+	case "date":
+		ok = task.Date == t.Value
+	case "from":
+		ok = task.Date >= t.Value
+	case "to":
+		ok = task.Date <= t.Value
+	default:
+		ok = contains(task.Title) || contains(task.Comment)
+	}
+	return ok != t.Not
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuery(t *testing.T) {
+	tbl := []struct {
+		query string
+		want  []Term
+	}{
+		{"", nil},
+		{"  отчёт  ", []Term{{Value: "отчёт"}}},
+		{"УК 18:00", []Term{{Value: "УК"}, {Value: "18:00"}}},
+		{`"годовой отчёт" -черновик`, []Term{{Value: "годовой отчёт"}, {Value: "черновик", Not: true}}},
+		{"01.03.2026", []Term{{Field: "date", Value: "20260301"}}},
+		{`"01.03.2026"`, []Term{{Value: "01.03.2026"}}},
+		{"from:01.03.2026 to:31.03.2026", []Term{{Field: "from", Value: "20260301"}, {Field: "to", Value: "20260331"}}},
+		{`Title:отчёт comment:"в 18:00"`, []Term{{Field: "title", Value: "отчёт"}, {Field: "comment", Value: "в 18:00"}}},
+		{`repeat:none -repeat:"D 7"`, []Term{{Field: "repeat", Value: "none"}, {Field: "repeat", Value: "d 7", Not: true}}},
+		// Отрицание границ не проверяется на порядок: это уже не диапазон
+		{"from:31.03.2026 -to:01.03.2026", []Term{{Field: "from", Value: "20260331"}, {Field: "to", Value: "20260301", Not: true}}},
+		{"e-mail", []Term{{Value: "e-mail"}}},
+		// Двоеточие после неизвестного имени — часть текста, как было до появления полей
+		{"owner:вася", []Term{{Value: "owner:вася"}}},
+		{"re:встреча https://example.com", []Term{{Value: "re:встреча"}, {Value: "https://example.com"}}},
+		{"-re:встреча", []Term{{Value: "re:встреча", Not: true}}},
+	}
+	for _, v := range tbl {
+		got, err := ParseQuery(v.query)
+		require.NoError(t, err, v.query)
+		assert.Equal(t, v.want, got, v.query)
+	}
+
+	for _, query := range []string{
+		"-",
+		"отчёт - черновик",
+		`"годовой отчёт`,
+		`"годовой"отчёт`,
+		`""`,
+		"title:",
+		"title: отчёт",
+		"from:2026-03-01",
+		"date:31.02.2026",
+		"from:31.03.2026 to:01.03.2026",
+		"a b c d e f g h i j k l m n o p q",
+	} {
+		_, err := ParseQuery(query)
+		assert.ErrorIs(t, err, ErrQuery, query)
+	}
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// SQLStore — хранилище задач в SQL-базе. Запросы общие для всех баз: идентификаторы,
+// совпадающие с ключевыми словами, берутся в двойные кавычки, а отличия баз
+// описывает dialect.
+type SQLStore struct {
+	db      *sql.DB
+	dialect *dialect
+}
+
+// dialect — то, чем SQL-базы отличаются друг от друга
+type dialect struct {
+	driver string // имя драйвера database/sql
+	// migrations — каталог встроенных миграций этой базы
+	migrations string
+	// dsn дополняет источник данных нужными хранилищу параметрами
+	dsn func(dsn string) string
+	// describe возвращает источник данных для журнала, без пароля
+	describe func(dsn string) string
+	// rebind переводит общий текст запроса на язык базы; nil — перевод не нужен
+	rebind func(query string) string
+	// forUpdate дописывается к SELECT, чтобы заблокировать строку до конца транзакции
+	forUpdate string
+	// returningID — ID новой строки возвращает INSERT ... RETURNING id, а не LastInsertId
+	returningID bool
+	// migrationLock берёт блокировку, под которой миграции выполняются по очереди
+	// с другими процессами, и возвращает соединение для них и функцию, снимающую
+	// блокировку; commit сообщает, успешно ли прошли миграции
+	migrationLock func(db *sql.DB) (c execer, release func(commit bool) error, err error)
+	// tableExists проверяет, есть ли в базе таблица
+	tableExists func(c execer, table string) (bool, error)
+	// adoptLegacy — у базы могут быть версии без schema_migrations, см. adoptLegacySchema
+	adoptLegacy bool
+}
+
+var dialects = map[string]*dialect{
+	DriverSQLite:   sqliteDialect,
+	DriverMySQL:    mysqlDialect,
+	DriverPostgres: postgresDialect,
+}
+
+// OpenSQL открывает SQL-хранилище driver с источником dsn, не трогая схему базы
+func OpenSQL(driver, dsn string) (*SQLStore, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("❌ Неизвестный драйвер базы %q: ожидается %s, %s, %s или %s", driver, DriverSQLite, DriverMySQL, DriverPostgres, DriverMemory)
+	}
+
+	db, err := sql.Open(d.driver, d.dsn(dsn))
+	if err != nil {
+		return nil, fmt.Errorf("❌ Ошибка при открытии базы данных: %w", err)
+	}
+
+	log.Printf("✅🔌 Подключаемся к базе %s: %s", driver, d.describe(dsn))
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("❌ Не удалось подключиться к базе: %w", err)
+	}
+	return &SQLStore{db: db, dialect: d}, nil
+}
+
+// Close закрывает соединения с базой
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// execer — общее у *sql.DB и *sql.Tx: запрос можно выполнить и в транзакции, и без неё
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// conn возвращает соединение с базой, которое переводит запросы на её язык
+func (s *SQLStore) conn() execer {
+	return s.dialect.wrap(s.db)
+}
+
+// tx — транзакция, которая переводит запросы на язык базы
+type tx struct {
+	execer
+	sqlTx *sql.Tx
+}
+
+func (t tx) Commit() error   { return t.sqlTx.Commit() }
+func (t tx) Rollback() error { return t.sqlTx.Rollback() }
+
+// begin открывает транзакцию
+func (s *SQLStore) begin() (tx, error) {
+	t, err := s.db.Begin()
+	if err != nil {
+		return tx{}, fmt.Errorf("ошибка при открытии транзакции: %w", err)
+	}
+	return tx{execer: s.dialect.wrap(t), sqlTx: t}, nil
+}
+
+// insert выполняет INSERT в таблицу с колонкой id и возвращает ID новой строки
+func (d *dialect) insert(c execer, query string, args ...any) (int64, error) {
+	if d.returningID {
+		var id int64
+		err := c.QueryRow(query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	res, err := c.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// wrap возвращает c, переводящий запросы на язык базы
+func (d *dialect) wrap(c execer) execer {
+	if d.rebind == nil {
+		return c
+	}
+	return rebinder{c, d.rebind}
+}
+
+type rebinder struct {
+	c      execer
+	rebind func(string) string
+}
+
+func (r rebinder) Exec(query string, args ...any) (sql.Result, error) {
+	return r.c.Exec(r.rebind(query), args...)
+}
+
+func (r rebinder) Query(query string, args ...any) (*sql.Rows, error) {
+	return r.c.Query(r.rebind(query), args...)
+}
+
+func (r rebinder) QueryRow(query string, args ...any) *sql.Row {
+	return r.c.QueryRow(r.rebind(query), args...)
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteOptions: транзакции сразу берут блокировку на запись (BEGIN IMMEDIATE),
+// поэтому чтение и запись в одной транзакции не пересекаются с другими писателями,
+// а конкурирующие соединения ждут блокировку, а не получают «database is locked».
+const sqliteOptions = "_txlock=immediate&_busy_timeout=5000"
+
+// sqliteDialect — SQLite; источник данных — путь к файлу базы
+var sqliteDialect = &dialect{
+	driver:     "sqlite3",
+	migrations: "migrations/sqlite",
+	dsn: func(path string) string {
+		if strings.Contains(path, "?") {
+			return path + "&" + sqliteOptions
+		}
+		return path + "?" + sqliteOptions
+	},
+	describe: func(path string) string { return path },
+	// Транзакция и так блокирует всю базу на запись
+	forUpdate: "",
+	// Транзакции открываются с BEGIN IMMEDIATE, поэтому транзакция миграций и служит
+	// блокировкой: два процесса, запущенные одновременно, применяют миграции по очереди,
+	// и второй видит уже обновлённую схему.
+	migrationLock: func(db *sql.DB) (execer, func(bool) error, error) {
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, nil, fmt.Errorf("ошибка при открытии транзакции: %w", err)
+		}
+		return tx, func(commit bool) error {
+			if !commit {
+				return tx.Rollback()
+			}
+			if err := tx.Commit(); err != nil {
+				return fmt.Errorf("ошибка при фиксации транзакции: %w", err)
+			}
+			return nil
+		}, nil
+	},
+	tableExists: func(c execer, table string) (bool, error) {
+		var n int
+		err := c.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&n)
+		if err != nil {
+			return false, fmt.Errorf("ошибка при проверке таблицы %s: %w", table, err)
+		}
+		return n > 0, nil
+	},
+	adoptLegacy: true,
+}
+
+// hasColumn проверяет, есть ли в таблице SQLite колонка
+func hasColumn(dbInstance execer, table, column string) (bool, error) {
+	rows, err := dbInstance.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
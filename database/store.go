@@ -0,0 +1,139 @@
+package database
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/naluneotlichno/FP-GO-API/nextdate"
+)
+
+// TaskStore — хранилище задач, их истории выполнения, корзины и токенов отмены.
+// Реализации: SQLStore поверх SQLite, MySQL или PostgreSQL и MemoryStore в памяти процесса.
+type TaskStore interface {
+	// AddTask добавляет новую задачу и возвращает её ID
+	AddTask(t Task) (int64, error)
+	// GetTaskByID возвращает задачу не из корзины; если такой нет — ErrTask
+	GetTaskByID(id int64) (Task, error)
+	// FindTasks возвращает задачи не из корзины, подходящие под фильтр,
+	// упорядоченные по дате, времени и ID
+	FindTasks(f TaskFilter) ([]Task, error)
+	// UpcomingTasks возвращает не больше limit задач не из корзины (0 — все) на их местах
+	// в списке предстоящих на момент now, начиная с места после after: просроченные
+	// повторяющиеся задачи стоят на ближайшем повторении. Задачи упорядочены по дате,
+	// времени и ID.
+	UpcomingTasks(now time.Time, after Cursor, limit int) ([]Task, error)
+	// UpdateTask обновляет задачу и увеличивает её версию. Если task.Version не 0,
+	// а версия задачи уже другая, возвращается ErrVersion.
+	UpdateTask(task Task) error
+	// DeleteTask удаляет задачу окончательно, минуя корзину
+	DeleteTask(id int64) error
+
+	// DoneTask отмечает выполнение задачи и переносит её на следующее повторение.
+	// Возвращает true, если повторений больше нет и задача удалена.
+	DoneTask(id int64, now time.Time, undo Undo, version int64) (bool, error)
+	// SkipTask добавляет дату в исключения повторяющейся задачи
+	SkipTask(id int64, date string, now time.Time) error
+	// UndoTask возвращает задачу в состояние, запомненное под токеном
+	UndoTask(token string, now time.Time) (Task, error)
+
+	// TrashTask отправляет задачу в корзину
+	TrashTask(id int64, now time.Time, undo Undo, version int64) error
+	// GetTrash возвращает задачи из корзины, начиная с удалённых последними
+	GetTrash() ([]Task, error)
+	// RestoreTask возвращает задачу из корзины
+	RestoreTask(id int64) error
+	// PurgeTrash окончательно удаляет задачи, попавшие в корзину раньше before
+	PurgeTrash(before time.Time) (int64, error)
+
+	// GetTaskHistory возвращает историю выполнения задачи, от ранних записей к поздним
+	GetTaskHistory(taskID int64) ([]Completion, error)
+	// GetCompletions возвращает выполнения за даты from..to включительно (YYYYMMDD)
+	GetCompletions(from, to string) ([]Completion, error)
+
+	Close() error
+}
+
+var (
+	_ TaskStore = (*SQLStore)(nil)
+	_ TaskStore = (*MemoryStore)(nil)
+)
+
+// TaskFilter — условия выборки задач; пустое поле выборку не ограничивает
+type TaskFilter struct {
+	Date  string // дата задачи YYYYMMDD
+	Text  string // подстрока заголовка или комментария
+	Terms []Term // условия поискового запроса из ParseQuery
+	After Cursor // задачи после этого места в списке
+	Limit int    // наибольшее число задач, 0 — без ограничения
+}
+
+// Cursor — место в списке задач, после которого продолжается выдача: дата задачи
+// в списке вместе со временем, как у nextdate.JoinDateTime, и её ID.
+// Нулевой курсор — начало списка.
+type Cursor struct {
+	Date string
+	ID   int64
+}
+
+// CursorOf возвращает место задачи task в списке, из которого она получена
+func CursorOf(task Task) Cursor {
+	return Cursor{Date: nextdate.JoinDateTime(task.Date, task.Time), ID: task.ID}
+}
+
+// IsZero сообщает, что курсор указывает на начало списка
+func (c Cursor) IsZero() bool { return c == Cursor{} }
+
+// before проверяет, что задача с датой date и ID id стоит в списке после курсора
+func (c Cursor) before(date string, id int64) bool {
+	return cmp.Or(cmp.Compare(date, c.Date), cmp.Compare(id, c.ID)) > 0
+}
+
+// Драйверы хранилища для TODO_DB_DRIVER
+const (
+	DriverSQLite   = "sqlite"
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+)
+
+// Config возвращает драйвер и источник данных хранилища из окружения: TODO_DB_DRIVER
+// (sqlite, mysql, postgres или memory; по умолчанию sqlite) и TODO_DB_DSN.
+// Для SQLite без TODO_DB_DSN берётся файл из GetDBPath.
+func Config() (driver, dsn string) {
+	driver, dsn = os.Getenv("TODO_DB_DRIVER"), os.Getenv("TODO_DB_DSN")
+	if driver == "" {
+		driver = DriverSQLite
+	}
+	if driver == DriverSQLite && dsn == "" {
+		dsn = GetDBPath()
+	}
+	return driver, dsn
+}
+
+// Open открывает хранилище driver с источником dsn и доводит схему базы до последней версии
+func Open(driver, dsn string) (TaskStore, error) {
+	if driver == DriverMemory {
+		log.Println("✅🔌 Задачи хранятся в памяти и пропадут при остановке сервера")
+		return NewMemoryStore(), nil
+	}
+
+	s, err := OpenSQL(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := s.MigrateUp()
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("❌ Ошибка при обновлении схемы базы: %w", err)
+	}
+	for _, m := range applied {
+		log.Printf("✅ Применена миграция %04d %s", m.Version, m.Name)
+	}
+
+	log.Printf("✅ Схема базы [%s] в актуальном состоянии", s.dialect.describe(dsn))
+	return s, nil
+}
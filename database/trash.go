@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/naluneotlichno/FP-GO-API/clock"
+)
+
+// TrashTask отправляет задачу в корзину на момент now, запоминая её под токеном undo.
+// Задачи в корзине не видны в списке, поиске и по ID, пока их не восстановят.
+// Если version не 0, а версия задачи уже другая, возвращается ErrVersion.
+func (s *SQLStore) TrashTask(id int64, now time.Time, undo Undo, version int64) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	task, err := getTaskByID(tx, id, s.dialect.forUpdate)
+	if err != nil {
+		return err
+	}
+	if version != 0 && task.Version != version {
+		return ErrVersion
+	}
+	if err := saveUndo(tx, undo, task, 0, task.Version+1); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE scheduler SET deleted_at = ?, version = version + 1 WHERE id = ?", now.Format(DoneAtLayout), id); err != nil {
+		return fmt.Errorf("ошибка при перемещении задачи в корзину: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка при фиксации транзакции: %w", err)
+	}
+	log.Printf("✅ [TrashTask] Задача ID=%d перемещена в корзину\n", id)
+	return nil
+}
+
+// GetTrash возвращает задачи из корзины, начиная с удалённых последними
+func (s *SQLStore) GetTrash() ([]Task, error) {
+	return queryTasks(s.conn(), "SELECT "+taskColumns+" FROM scheduler WHERE deleted_at != '' ORDER BY deleted_at DESC, id DESC")
+}
+
+// RestoreTask возвращает задачу из корзины
+func (s *SQLStore) RestoreTask(id int64) error {
+	res, err := s.conn().Exec("UPDATE scheduler SET deleted_at = '', version = version + 1 WHERE id = ? AND deleted_at != ''", id)
+	if err != nil {
+		return fmt.Errorf("ошибка при восстановлении задачи: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка при получении количества затронутых строк: %w", err)
+	}
+	if n == 0 {
+		return ErrTask
+	}
+
+	log.Printf("✅ [RestoreTask] Задача ID=%d восстановлена из корзины\n", id)
+	return nil
+}
+
+// PurgeTrash окончательно удаляет задачи, попавшие в корзину раньше before
+func (s *SQLStore) PurgeTrash(before time.Time) (int64, error) {
+	res, err := s.conn().Exec("DELETE FROM scheduler WHERE deleted_at != '' AND deleted_at < ?", before.Format(DoneAtLayout))
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при очистке корзины: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// RunTrashPurge раз в every очищает корзину хранилища s от задач, пролежавших в ней дольше
+// retention по часам c. Работает, пока не отменён ctx, поэтому запускается в отдельной горутине.
+func RunTrashPurge(ctx context.Context, s TaskStore, c clock.Clock, retention, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.PurgeTrash(c.Now().Add(-retention))
+		if err != nil {
+			log.Printf("🚨 [RunTrashPurge] Ошибка очистки корзины: %v\n", err)
+		} else if n > 0 {
+			log.Printf("✅ [RunTrashPurge] Из корзины удалено задач: %d\n", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
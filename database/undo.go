@@ -0,0 +1,147 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/naluneotlichno/FP-GO-API/clock"
+)
+
+var (
+	ErrUndo        = errors.New("нечего отменять")
+	ErrUndoExpired = errors.New("время на отмену истекло")
+)
+
+// Undo — токен отмены, когда он выдан и до какого момента действует.
+// Пустой токен — отмена не нужна.
+type Undo struct {
+	Token     string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// saveUndo запоминает задачу task в прежнем состоянии под токеном undo.
+// completionID — запись истории, которую нужно будет удалить при отмене, 0 — такой нет.
+// version — версия, которую задача получит после действия: если к отмене она уже
+// другая, задачу успели изменить, и отмена вернёт ErrVersion.
+// Заодно удаляются просроченные токены и прежние токены этой задачи.
+func saveUndo(dbInstance execer, undo Undo, task Task, completionID, version int64) error {
+	if undo.Token == "" {
+		return nil
+	}
+
+	state, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("ошибка при сохранении состояния задачи: %w", err)
+	}
+
+	_, err = dbInstance.Exec("DELETE FROM task_undo WHERE task_id = ? OR expires_at < ?", task.ID, undo.IssuedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("ошибка при удалении старых токенов отмены: %w", err)
+	}
+
+	_, err = dbInstance.Exec("INSERT INTO task_undo (token, task_id, task, completion_id, version, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+		undo.Token, task.ID, string(state), completionID, version, undo.ExpiresAt.Unix())
+	if err != nil {
+		return fmt.Errorf("ошибка при сохранении токена отмены: %w", err)
+	}
+	return nil
+}
+
+// UndoTask возвращает задачу в состояние, запомненное под токеном, если на момент now
+// он ещё действует. Запись истории о выполнении при этом удаляется.
+// Если задачу изменили после действия, возвращается ErrVersion, а токен пропадает.
+func (s *SQLStore) UndoTask(token string, now time.Time) (Task, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return Task{}, err
+	}
+	defer tx.Rollback()
+
+	var (
+		state        string
+		completionID int64
+		after        int64
+		expiresAt    int64
+	)
+	err = tx.QueryRow("SELECT task, completion_id, version, expires_at FROM task_undo WHERE token = ?"+s.dialect.forUpdate, token).Scan(&state, &completionID, &after, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Task{}, ErrUndo
+	}
+	if err != nil {
+		return Task{}, fmt.Errorf("ошибка при чтении токена отмены: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM task_undo WHERE token = ?", token); err != nil {
+		return Task{}, fmt.Errorf("ошибка при удалении токена отмены: %w", err)
+	}
+	if now.Unix() > expiresAt {
+		// Просроченный токен всё равно удаляем
+		if err := tx.Commit(); err != nil {
+			return Task{}, fmt.Errorf("ошибка при фиксации транзакции: %w", err)
+		}
+		return Task{}, ErrUndoExpired
+	}
+
+	var task Task
+	if err := json.Unmarshal([]byte(state), &task); err != nil {
+		return Task{}, fmt.Errorf("ошибка при чтении состояния задачи: %w", err)
+	}
+
+	// Версия продолжает расти и после отмены: старый ETag не должен снова стать верным
+	var current int64
+	err = tx.QueryRow("SELECT version FROM scheduler WHERE id = ?"+s.dialect.forUpdate, task.ID).Scan(&current)
+	exists := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Task{}, fmt.Errorf("ошибка при чтении задачи: %w", err)
+	}
+	// Отмена затёрла бы изменение, сделанное после действия; токен всё равно удаляем
+	if exists && after != 0 && current != after {
+		if err := tx.Commit(); err != nil {
+			return Task{}, fmt.Errorf("ошибка при фиксации транзакции: %w", err)
+		}
+		return Task{}, ErrVersion
+	}
+	task.Version = max(task.Version, current) + 1
+
+	// Прежняя дата могла уже пройти: место в списке считается сразу на момент now
+	next, final, err := upcomingDate(task, now)
+	if err != nil {
+		return Task{}, err
+	}
+	refresh := refreshAt(task, next, final, clock.In(now, task.TZ).Location())
+
+	// Выполненная разовая задача уже удалена, а задача из корзины ещё на месте
+	if exists {
+		_, err = tx.Exec(`UPDATE scheduler
+			SET date = ?, title = ?, comment = ?, "repeat" = ?, remaining = ?, time = ?, duration = ?, tz = ?, exdates = ?,
+			    next_date = ?, refresh_at = ?, title_lower = ?, comment_lower = ?, deleted_at = '', version = ?
+			WHERE id = ?`,
+			task.Date, task.Title, task.Comment, task.Repeat, task.Remaining, task.Time, task.Duration, task.TZ, joinDates(task.ExDates),
+			next, refresh, strings.ToLower(task.Title), strings.ToLower(task.Comment), task.Version, task.ID)
+	} else {
+		_, err = tx.Exec(`INSERT INTO scheduler (id, date, title, comment, "repeat", remaining, time, duration, tz, exdates, next_date, refresh_at, title_lower, comment_lower, version)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			task.ID, task.Date, task.Title, task.Comment, task.Repeat, task.Remaining, task.Time, task.Duration, task.TZ, joinDates(task.ExDates),
+			next, refresh, strings.ToLower(task.Title), strings.ToLower(task.Comment), task.Version)
+	}
+	if err != nil {
+		return Task{}, fmt.Errorf("ошибка при восстановлении задачи: %w", err)
+	}
+	if completionID > 0 {
+		if _, err := tx.Exec("DELETE FROM task_completions WHERE id = ?", completionID); err != nil {
+			return Task{}, fmt.Errorf("ошибка при удалении записи о выполнении: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Task{}, fmt.Errorf("ошибка при фиксации транзакции: %w", err)
+	}
+	log.Printf("✅ [UndoTask] Задача ID=%d восстановлена\n", task.ID)
+	return task, nil
+}
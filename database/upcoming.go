@@ -0,0 +1,188 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/naluneotlichno/FP-GO-API/clock"
+	"github.com/naluneotlichno/FP-GO-API/nextdate"
+)
+
+// easternmost — самый восточный часовой пояс (UTC+14): в нём новые сутки наступают раньше всего
+var easternmost = time.FixedZone("UTC+14", 14*60*60)
+
+// refreshLayout — формат колонки refresh_at: момент в UTC
+const refreshLayout = DoneAtLayout
+
+// refreshBatchSize — сколько задач refreshUpcoming пересчитывает одной транзакцией
+const refreshBatchSize = 500
+
+// upcomingDate возвращает место задачи в списке предстоящих на момент now: дату
+// со временем в виде nextdate.JoinDateTime. Задача на сегодня и будущая стоят на своей дате,
+// просроченная повторяющаяся — на ближайшем повторении, минуя даты-исключения.
+// «Сегодня» для задачи считается в её часовом поясе, а если он не задан — в поясе now.
+// final сообщает, что место уже не изменится: задача не повторяется или повторения закончились.
+func upcomingDate(task Task, now time.Time) (next string, final bool, err error) {
+	if _, err := time.Parse("20060102", task.Date); err != nil {
+		return "", false, fmt.Errorf("ошибка при разборе даты задачи ID %d: %w", task.ID, err)
+	}
+
+	start := nextdate.JoinDateTime(task.Date, task.Time)
+	taskNow := clock.In(now, task.TZ)
+	if task.Repeat == "" || task.Date >= taskNow.Format("20060102") {
+		return start, task.Repeat == "", nil
+	}
+
+	next, err = nextdate.NextDate(taskNow, start, task.Repeat, "list", task.ExDates...)
+	switch {
+	case errors.Is(err, nextdate.ErrNoOccurrences):
+		// Повторения закончились: задача остаётся на своей дате, пока её не отметят выполненной
+		return start, true, nil
+	case err != nil:
+		return "", false, fmt.Errorf("ошибка при вычислении следующей даты для задачи ID %d: %w", task.ID, err)
+	}
+	date, hhmm := nextdate.SplitDateTime(next)
+	if hhmm == "" {
+		hhmm = task.Time
+	}
+	return nextdate.JoinDateTime(date, hhmm), false, nil
+}
+
+// refreshAt возвращает для колонки refresh_at момент, когда место next повторяющейся
+// задачи устареет: в полночь после его даты по часам задачи. loc — пояс, в котором
+// считается «сегодня» для задачи без своего пояса; nil — пояс неизвестен, и берётся
+// самый ранний момент из возможных. Пустая строка — пересчитывать место не нужно.
+func refreshAt(task Task, next string, final bool, loc *time.Location) string {
+	if final || task.Repeat == "" {
+		return ""
+	}
+	if zone, err := clock.LoadZone(task.TZ); err == nil && zone != nil {
+		loc = zone
+	}
+	if loc == nil {
+		loc = easternmost
+	}
+	date, _ := nextdate.SplitDateTime(next)
+	day, err := time.ParseInLocation("20060102", date, loc)
+	if err != nil {
+		// Некорректную дату сразу отдаём пересчёту: upcomingDate сообщит об ошибке
+		return time.Time{}.Format(refreshLayout)
+	}
+	return day.AddDate(0, 0, 1).UTC().Format(refreshLayout)
+}
+
+// placeUpcoming ставит задачу на её место next в списке предстоящих
+func placeUpcoming(task *Task, next string) {
+	task.Date, task.Time = nextdate.SplitDateTime(next)
+}
+
+// UpcomingTasks возвращает список предстоящих задач одним запросом по индексу
+// idx_next_date. Колонка next_date хранит место задачи в списке и записывается
+// вместе с задачей, а у повторяющихся задач, чьё место устарело, её сначала
+// переносит вперёд refreshUpcoming.
+func (s *SQLStore) UpcomingTasks(now time.Time, after Cursor, limit int) ([]Task, error) {
+	if err := s.refreshUpcoming(now); err != nil {
+		return nil, err
+	}
+
+	query, args := "SELECT "+taskColumns+", next_date FROM scheduler WHERE deleted_at = ''", []any{}
+	if !after.IsZero() {
+		query += " AND (next_date, id) > (?, ?)"
+		args = append(args, after.Date, after.ID)
+	}
+	query += " ORDER BY next_date, id"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	tasks, next, err := queryUpcoming(s.conn(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tasks {
+		placeUpcoming(&tasks[i], next[i])
+	}
+
+	log.Printf("✅ [UpcomingTasks] Получено %d задач\n", len(tasks))
+	return tasks, nil
+}
+
+// refreshUpcoming пересчитывает next_date повторяющихся задач, чьё место в списке
+// устарело к моменту now: их выбирает индекс idx_refresh_at по колонке refresh_at.
+// Место пересчитывается один раз за каждое прошедшее повторение, у неповторяющихся
+// и закончившихся задач — никогда, поэтому обычно запрос ничего не находит и
+// транзакция не открывается. Накопившиеся задачи пересчитываются пачками по refreshBatchSize.
+func (s *SQLStore) refreshUpcoming(now time.Time) error {
+	due := now.UTC().Format(refreshLayout)
+	for {
+		tasks, _, err := queryUpcoming(s.conn(), "SELECT "+taskColumns+", next_date FROM scheduler WHERE refresh_at > '' AND refresh_at <= ? ORDER BY refresh_at, id LIMIT ?", due, refreshBatchSize)
+		if err != nil || len(tasks) == 0 {
+			return err
+		}
+		if err := s.saveUpcoming(tasks, now); err != nil {
+			return err
+		}
+		if len(tasks) < refreshBatchSize {
+			return nil
+		}
+	}
+}
+
+// saveUpcoming пересчитывает на момент now места задач tasks и записывает их одной транзакцией
+func (s *SQLStore) saveUpcoming(tasks []Task, now time.Time) error {
+	batch, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer batch.Rollback()
+
+	for _, task := range tasks {
+		next, final, err := upcomingDate(task, now)
+		if err != nil {
+			return err
+		}
+		// Место могло и не измениться: тогда запоминается только, когда проверить его снова
+		refresh := refreshAt(task, next, final, clock.In(now, task.TZ).Location())
+		// Если задачу успели изменить, next_date и refresh_at уже записаны вместе с ней
+		if _, err := batch.Exec("UPDATE scheduler SET next_date = ?, refresh_at = ? WHERE id = ? AND version = ?", next, refresh, task.ID, task.Version); err != nil {
+			return fmt.Errorf("ошибка при обновлении места задачи ID %d в списке: %w", task.ID, err)
+		}
+	}
+	if err := batch.Commit(); err != nil {
+		return fmt.Errorf("ошибка при фиксации транзакции: %w", err)
+	}
+	return nil
+}
+
+// queryUpcoming выполняет запрос, выбирающий колонки taskColumns и next_date,
+// и читает задачи вместе с их местом в списке предстоящих
+func queryUpcoming(dbInstance execer, query string, args ...any) ([]Task, []string, error) {
+	rows, err := dbInstance.Query(query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка при выполнении запроса: %w", err)
+	}
+	defer rows.Close()
+
+	tasks, next := []Task{}, []string{}
+	for rows.Next() {
+		var date string
+		task, err := scanTask(scanFunc(func(dest ...any) error {
+			return rows.Scan(append(dest, &date)...)
+		}))
+		if err != nil {
+			return nil, nil, fmt.Errorf("ошибка при чтении строки из результата: %w", err)
+		}
+		tasks, next = append(tasks, task), append(next, date)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("ошибка при обработке результатов запроса: %w", err)
+	}
+	return tasks, next, nil
+}
+
+// scanFunc позволяет передать в scanTask функцию, дочитывающую дополнительные колонки
+type scanFunc func(dest ...any) error
+
+func (f scanFunc) Scan(dest ...any) error { return f(dest...) }
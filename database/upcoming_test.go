@@ -0,0 +1,180 @@
+package database
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/naluneotlichno/FP-GO-API/nextdate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// BenchmarkUpcomingTasks сравнивает список предстоящих задач по индексу next_date
+// с прежним способом — расчётом места каждой задачи при каждом запросе:
+//
+//	go test ./database -run '^$' -bench UpcomingTasks -benchmem
+//
+// Запросы идут в пределах одного часа: места, устаревшие к его началу, пересчитывает
+// первый запрос, а дальше каждый запрос — это выборка по индексам idx_refresh_at и idx_next_date.
+func BenchmarkUpcomingTasks(b *testing.B) {
+	// Журнал каждого запроса и расчёта даты заглушил бы результаты
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, n := range []int{1_000, 10_000, 100_000} {
+		b.Run(fmt.Sprintf("SQLite/%d", n), func(b *testing.B) {
+			s := openBench(b, n, now)
+			benchmarkUpcoming(b, now, s.UpcomingTasks)
+		})
+		b.Run(fmt.Sprintf("FullScan/%d", n), func(b *testing.B) {
+			s := openBench(b, n, now)
+			benchmarkUpcoming(b, now, func(now time.Time, _ Cursor, limit int) ([]Task, error) {
+				return fullScanUpcoming(s, now, limit)
+			})
+		})
+		b.Run(fmt.Sprintf("Memory/%d", n), func(b *testing.B) {
+			s := NewMemoryStore()
+			for i := range n {
+				_, err := s.AddTask(benchTask(i, now))
+				require.NoError(b, err)
+			}
+			benchmarkUpcoming(b, now, s.UpcomingTasks)
+		})
+	}
+}
+
+func benchmarkUpcoming(b *testing.B, now time.Time, upcoming func(time.Time, Cursor, int) ([]Task, error)) {
+	// Первый вызов один раз переносит все давно просроченные задачи
+	_, err := upcoming(now, Cursor{}, 50)
+	require.NoError(b, err)
+
+	b.ResetTimer()
+	for i := range b.N {
+		tasks, err := upcoming(now.Add(time.Duration(i%3600)*time.Second), Cursor{}, 50)
+		require.NoError(b, err)
+		require.Len(b, tasks, 50)
+	}
+}
+
+// openBench открывает базу SQLite с n задачами из benchTask
+func openBench(b *testing.B, n int, now time.Time) *SQLStore {
+	s, err := Open(DriverSQLite, filepath.Join(b.TempDir(), "scheduler.db"))
+	require.NoError(b, err)
+	b.Cleanup(func() { s.Close() })
+	fillTasks(b, s.(*SQLStore), n, now)
+	return s.(*SQLStore)
+}
+
+// fullScanUpcoming строит список так, как до колонки next_date: читает все задачи
+// и считает место каждой
+func fullScanUpcoming(s *SQLStore, now time.Time, limit int) ([]Task, error) {
+	tasks, err := queryTasks(s.conn(), "SELECT "+taskColumns+" FROM scheduler WHERE deleted_at = ''")
+	if err != nil {
+		return nil, err
+	}
+	next := make(map[int64]string, len(tasks))
+	for _, task := range tasks {
+		if next[task.ID], _, err = upcomingDate(task, now); err != nil {
+			return nil, err
+		}
+	}
+	slices.SortFunc(tasks, func(a, b Task) int {
+		return cmp.Or(cmp.Compare(next[a.ID], next[b.ID]), cmp.Compare(a.ID, b.ID))
+	})
+	tasks = tasks[:min(limit, len(tasks))]
+	for i := range tasks {
+		placeUpcoming(&tasks[i], next[tasks[i].ID])
+	}
+	return tasks, nil
+}
+
+func TestRefreshUpcoming(t *testing.T) {
+	store, err := Open(DriverSQLite, filepath.Join(t.TempDir(), "scheduler.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	s := store.(*SQLStore)
+
+	add := func(task Task) int64 {
+		id, err := s.AddTask(task)
+		require.NoError(t, err)
+		return id
+	}
+	weekly := add(Task{Date: "20240101", Title: "Каждую неделю", Repeat: "d 7"})
+	once := add(Task{Date: "20240101", Title: "Разовая"})
+	ended := add(Task{Date: "20240101", Title: "Закончилась", Repeat: "d 7 until 20240110"})
+	moscow := add(Task{Date: "20240101", Title: "По Москве", Repeat: "d 7", TZ: "Europe/Moscow"})
+
+	refreshAt := func(id int64) string {
+		var at string
+		require.NoError(t, s.db.QueryRow("SELECT refresh_at FROM scheduler WHERE id = ?", id).Scan(&at))
+		return at
+	}
+	due := func(now time.Time) int {
+		var n int
+		require.NoError(t, s.db.QueryRow("SELECT count(*) FROM scheduler WHERE refresh_at > '' AND refresh_at <= ?", now.UTC().Format(refreshLayout)).Scan(&n))
+		return n
+	}
+	// Без известного пояса место считается устаревшим с наступлением суток в UTC+14
+	assert.Equal(t, "20240101 10:00:00", refreshAt(weekly))
+	assert.Empty(t, refreshAt(once))
+
+	now := time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, 3, due(now))
+	tasks, err := s.UpcomingTasks(now, Cursor{}, 0)
+	require.NoError(t, err)
+	require.Len(t, tasks, 4)
+
+	// Повторяющаяся задача переехала на ближайшее повторение и будет пересчитана в полночь после него;
+	// у закончившейся и разовой пересчитывать нечего
+	assert.Equal(t, "20240206 00:00:00", refreshAt(weekly))
+	assert.Equal(t, "20240205 21:00:00", refreshAt(moscow))
+	assert.Empty(t, refreshAt(ended))
+	assert.Empty(t, refreshAt(once))
+	assert.Zero(t, due(now))
+	assert.Zero(t, due(time.Date(2024, 2, 5, 20, 59, 0, 0, time.UTC)))
+
+	// Выполнение сразу записывает новое место
+	_, err = s.DoneTask(weekly, now, Undo{}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "20240206 00:00:00", refreshAt(weekly))
+	assert.Zero(t, due(now))
+}
+
+// benchTask — задача i из набора, где даты разбросаны на десять лет вокруг now,
+// а каждая пятая задача повторяется
+func benchTask(i int, now time.Time) Task {
+	task := Task{
+		Date:  now.AddDate(0, 0, i%3650-365).Format("20060102"),
+		Title: fmt.Sprintf("Задача %d", i),
+	}
+	if i%5 == 0 {
+		task.Repeat = "d 7"
+	}
+	if i%3 == 0 {
+		task.Time = "09:30"
+	}
+	return task
+}
+
+// fillTasks добавляет n задач одной транзакцией: по одной это заняло бы минуты
+func fillTasks(b *testing.B, s *SQLStore, n int, now time.Time) {
+	tx, err := s.begin()
+	require.NoError(b, err)
+	defer tx.Rollback()
+	for i := range n {
+		t := benchTask(i, now)
+		next := nextdate.JoinDateTime(t.Date, t.Time)
+		_, err := tx.Exec(`INSERT INTO scheduler (date, title, comment, "repeat", time, next_date, refresh_at) VALUES (?, ?, '', ?, ?, ?, ?)`,
+			t.Date, t.Title, t.Repeat, t.Time, next, refreshAt(t, next, false, nil))
+		require.NoError(b, err)
+	}
+	require.NoError(b, tx.Commit())
+}
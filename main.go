@@ -1,29 +1,92 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
+	_ "time/tzdata" // база часовых поясов встроена на случай образа без tzdata
 
 	"github.com/go-chi/chi/v5"
 	"github.com/naluneotlichno/FP-GO-API/api"
+	"github.com/naluneotlichno/FP-GO-API/clock"
 	"github.com/naluneotlichno/FP-GO-API/database"
 	"github.com/naluneotlichno/FP-GO-API/nextdate"
 )
 
+// defaultTrashRetention — сколько задачи хранятся в корзине по умолчанию: 30 дней
+const defaultTrashRetention = 30 * 24 * time.Hour
+
 func main() {
+	migrate := flag.String("migrate", "", "управление схемой базы без запуска сервера: up, down или status")
+	flag.Parse()
+	if *migrate != "" {
+		runMigrate(*migrate)
+		return
+	}
+
 	log.Println("✅ 🔥 Запускаем нашего монстра!")
 
-	// ✅ Инициализация базы данных
-	if err := database.InitDB(database.GetDBPath()); err != nil {
+	// ✅ Инициализация хранилища: SQLite, MySQL или память — по TODO_DB_DRIVER
+	store, err := database.Open(database.Config())
+	if err != nil {
 		log.Fatalf("❌ Ошибка инициализации БД: %v", err)
 	}
 
+	// ✅ Загрузка производственного календаря для правил workdays и shift
+	if path := os.Getenv("TODO_HOLIDAYS"); path != "" {
+		calendar, err := nextdate.LoadCalendar(path)
+		if err != nil {
+			log.Fatalf("❌ Ошибка загрузки календаря: %v", err)
+		}
+		nextdate.SetCalendar(calendar)
+		log.Printf("✅ 📅 Загружен производственный календарь: %s", path)
+	}
+
+	// ✅ Часовой пояс по умолчанию: в нём считается «сегодня» для задач без своего пояса
+	c := clock.System
+	if tz := os.Getenv("TODO_TZ"); tz != "" {
+		loc, err := clock.LoadZone(tz)
+		if err != nil {
+			log.Fatalf("❌ Ошибка настройки часового пояса: %v", err)
+		}
+		c = clock.InZone(c, loc)
+		log.Printf("✅ 🕒 Часовой пояс по умолчанию: %s", tz)
+	}
+
+	// ✅ Сколько можно отменить выполнение или удаление задачи
+	undoWindow := api.DefaultUndoWindow
+	if v := os.Getenv("TODO_UNDO_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Fatalf("❌ Некорректный TODO_UNDO_WINDOW=%q: ожидается длительность вроде 30s или 5m", v)
+		}
+		undoWindow = d
+		log.Printf("✅ ↩️ Окно отмены: %s", d)
+	}
+
+	// ✅ Сколько задачи лежат в корзине, прежде чем удалиться окончательно
+	retention := defaultTrashRetention
+	if v := os.Getenv("TODO_TRASH_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Fatalf("❌ Некорректный TODO_TRASH_RETENTION=%q: ожидается длительность вроде 72h", v)
+		}
+		retention = d
+	}
+	if retention > 0 {
+		go database.RunTrashPurge(context.Background(), store, c, retention, min(retention, time.Hour))
+		log.Printf("✅ 🗑 Корзина очищается от задач старше %s", retention)
+	}
+
 	// ✅ Создание маршрутизатора
 	r := chi.NewRouter()
 
 	// ✅ Регистрация хендлеров
-	registerHandlers(r)
+	registerHandlers(r, store, c, undoWindow)
 
 	// ✅ Подключение файлов /web
 	webDir := "./web"
@@ -34,15 +97,78 @@ func main() {
 	startServer(r)
 }
 
-// 🔥 registerHandlers регистрирует все хендлеры
-func registerHandlers(r *chi.Mux) {
-	r.Get("/api/nextdate", nextdate.HandleNextDate) // +
-	r.Post("/api/task", api.AddTaskHandler)         // +
-	r.Get("/api/tasks", api.Tasks)                  // +
-	r.Get("/api/task", api.GetTaskHandler)          // +
-	r.Put("/api/task", api.UpdateTaskHandler)       // +
-	r.Post("/api/task/done", api.DoneTaskHandler)   // +
-	r.Delete("/api/task", api.DeleteTaskHandler)    // +
+// 🔥 runMigrate выполняет команду -migrate: up применяет все новые миграции,
+// down откатывает последнюю, status показывает, какие применены
+func runMigrate(command string) {
+	driver, dsn := database.Config()
+	if driver == database.DriverMemory {
+		log.Fatalf("❌ У хранилища %s нет схемы, которую можно мигрировать", driver)
+	}
+	store, err := database.OpenSQL(driver, dsn)
+	if err != nil {
+		log.Fatalf("❌ Ошибка открытия БД: %v", err)
+	}
+	defer store.Close()
+
+	switch command {
+	case "up":
+		applied, err := store.MigrateUp()
+		if err != nil {
+			log.Fatalf("❌ Ошибка применения миграций: %v", err)
+		}
+		for _, m := range applied {
+			fmt.Printf("применена %04d %s\n", m.Version, m.Name)
+		}
+		if len(applied) == 0 {
+			fmt.Println("схема уже в актуальном состоянии")
+		}
+	case "down":
+		m, ok, err := store.MigrateDown()
+		if err != nil {
+			log.Fatalf("❌ Ошибка отката миграции: %v", err)
+		}
+		if !ok {
+			fmt.Println("откатывать нечего")
+			return
+		}
+		fmt.Printf("откачена %04d %s\n", m.Version, m.Name)
+	case "status":
+		states, err := store.MigrationStatus()
+		if err != nil {
+			log.Fatalf("❌ Ошибка чтения состояния миграций: %v", err)
+		}
+		for _, s := range states {
+			applied := "не применена"
+			if s.AppliedAt != "" {
+				applied = "применена " + s.AppliedAt
+			}
+			fmt.Printf("%04d %-28s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		log.Fatalf("❌ Неизвестная команда -migrate=%s: ожидается up, down или status", command)
+	}
+}
+
+// 🔥 registerHandlers регистрирует все хендлеры; задачи они хранят в store, текущее время
+// берут из c, а выполнение и удаление можно отменить в течение undoWindow
+func registerHandlers(r *chi.Mux, store database.TaskStore, c clock.Clock, undoWindow time.Duration) {
+	srv := api.NewServer(c, store)
+	srv.UndoWindow = undoWindow
+
+	r.Get("/api/nextdate", nextdate.HandleNextDate)                    // +
+	r.Get("/api/nextdate/occurrences", nextdate.OccurrencesHandler(c)) // +
+	r.Post("/api/task", srv.AddTaskHandler)                            // +
+	r.Get("/api/tasks", srv.Tasks)                                     // +
+	r.Get("/api/task", srv.GetTaskHandler)                             // +
+	r.Put("/api/task", srv.UpdateTaskHandler)                          // +
+	r.Post("/api/task/done", srv.DoneTaskHandler)                      // +
+	r.Post("/api/task/skip", srv.SkipTaskHandler)                      // +
+	r.Post("/api/task/undo", srv.UndoTaskHandler)                      // +
+	r.Get("/api/trash", srv.TrashHandler)                              // +
+	r.Post("/api/trash/restore", srv.RestoreTaskHandler)               // +
+	r.Get("/api/task/history", srv.TaskHistoryHandler)                 // +
+	r.Get("/api/completions", srv.CompletionsHandler)                  // +
+	r.Delete("/api/task", srv.DeleteTaskHandler)                       // +
 }
 
 // 🔥 startServer запускает сервер
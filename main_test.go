@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/naluneotlichno/FP-GO-API/clock"
+	"github.com/naluneotlichno/FP-GO-API/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRouter собирает маршруты так же, как main, поверх хранилища в памяти
+func newTestRouter(t *testing.T) *chi.Mux {
+	t.Helper()
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	r := chi.NewRouter()
+	registerHandlers(r, database.NewMemoryStore(), clock.Func(func() time.Time { return now }), time.Minute)
+	return r
+}
+
+// request выполняет запрос через маршрутизатор и разбирает JSON-ответ
+func request(t *testing.T, r http.Handler, method, target string, body any) (int, map[string]any) {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(method, target, &buf))
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m), rec.Body.String())
+	return rec.Code, m
+}
+
+// addTasks добавляет задачи через API и возвращает их ID
+func addTasks(t *testing.T, r http.Handler, tasks ...map[string]string) []string {
+	t.Helper()
+	var ids []string
+	for _, task := range tasks {
+		code, m := request(t, r, http.MethodPost, "/api/task", task)
+		require.Equal(t, http.StatusCreated, code, m)
+		ids = append(ids, m["id"].(string))
+	}
+	return ids
+}
+
+// search выполняет GET /api/tasks?search=query и возвращает ID найденных задач
+func search(t *testing.T, r http.Handler, query string) (int, []string, map[string]any) {
+	t.Helper()
+	code, m := request(t, r, http.MethodGet, "/api/tasks?search="+url.QueryEscape(query), nil)
+	var ids []string
+	if list, ok := m["tasks"].([]any); ok {
+		for _, item := range list {
+			ids = append(ids, item.(map[string]any)["id"].(string))
+		}
+	}
+	return code, ids, m
+}
+
+func TestSearchRoute(t *testing.T) {
+	r := newTestRouter(t)
+	ids := addTasks(t, r,
+		map[string]string{"date": "20260302", "title": "Отчёт alpha", "repeat": "m 2"},
+		map[string]string{"date": "20260315", "title": "Бассейн"},
+		map[string]string{"date": "20260401", "title": "Отчёт beta"},
+	)
+
+	code, found, m := search(t, r, "title:alpha")
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, []string{ids[0]}, found)
+
+	code, found, m = search(t, r, "from:01.03.2026 to:31.03.2026 -repeat:any")
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, []string{ids[1]}, found)
+
+	code, _, m = search(t, r, "from:2026-03-01")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, m["error"], "некорректная дата")
+
+	// Ссылка — обычный текст, а не поле https
+	code, found, m = search(t, r, "https://example.com")
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Empty(t, found)
+
+	// Без search — по-прежнему список предстоящих задач
+	code, m = request(t, r, http.MethodGet, "/api/tasks", nil)
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Len(t, m["list"], 3)
+}
+
+func TestSearchPaginationRoute(t *testing.T) {
+	r := newTestRouter(t)
+	var want []string
+	for day := 2; day <= 8; day++ {
+		want = append(want, addTasks(t, r, map[string]string{"date": fmt.Sprintf("202603%02d", day), "title": "Отчёт"})...)
+	}
+	addTasks(t, r, map[string]string{"date": "20260303", "title": "Бассейн"})
+
+	var got []string
+	pages := 0
+	for cursor := ""; ; {
+		target := "/api/tasks?search=" + url.QueryEscape("title:Отчёт") + "&limit=3&cursor=" + cursor
+		code, m := request(t, r, http.MethodGet, target, nil)
+		require.Equal(t, http.StatusOK, code, m)
+		pages++
+		for _, item := range m["tasks"].([]any) {
+			got = append(got, item.(map[string]any)["id"].(string))
+		}
+		next, ok := m["next_cursor"].(string)
+		if !ok {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(t, want, got)
+	assert.Equal(t, 3, pages)
+
+	code, m := request(t, r, http.MethodGet, "/api/tasks?search=Отчёт&limit=0", nil)
+	assert.Equal(t, http.StatusBadRequest, code, m)
+}
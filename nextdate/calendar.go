@@ -0,0 +1,144 @@
+package nextdate
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Calendar — производственный календарь: праздничные дни и перенесённые
+// рабочие дни. Суббота и воскресенье считаются выходными, если календарь
+// явно не объявил их рабочими.
+type Calendar struct {
+	holidays map[time.Time]bool
+	workdays map[time.Time]bool
+}
+
+// calendar используется правилами с модификаторами workdays и shift.
+// По умолчанию праздников нет, выходные — только суббота и воскресенье.
+var calendar = &Calendar{}
+
+// SetCalendar задаёт календарь, по которому считаются рабочие дни.
+// Вызывается при старте, до начала обработки запросов.
+func SetCalendar(c *Calendar) {
+	if c == nil {
+		c = &Calendar{}
+	}
+	calendar = c
+}
+
+// IsWorkday сообщает, рабочий ли день d.
+func (c *Calendar) IsWorkday(d time.Time) bool {
+	d = dateOf(d)
+	if c.workdays[d] {
+		return true
+	}
+	if c.holidays[d] {
+		return false
+	}
+	wd := isoWeekday(d)
+	return wd != 6 && wd != 7
+}
+
+// calendarFile — формат JSON-файла календаря.
+type calendarFile struct {
+	Holidays []string `json:"holidays"` // нерабочие праздничные дни
+	Workdays []string `json:"workdays"` // рабочие субботы и воскресенья
+}
+
+// LoadCalendar читает календарь из файла. Поддерживаются два формата:
+//
+//   - JSON: {"holidays": ["20260101", ...], "workdays": ["20261228", ...]};
+//   - CSV: строки "дата" или "дата,holiday" для праздников и "дата,workday"
+//     для рабочих выходных.
+//
+// Даты записываются как YYYYMMDD или YYYY-MM-DD. Формат определяется по
+// расширению файла: .json — JSON, всё остальное — CSV.
+func LoadCalendar(path string) (*Calendar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("calendar: не удалось открыть файл: %w", err)
+	}
+	defer f.Close()
+
+	c := &Calendar{holidays: map[time.Time]bool{}, workdays: map[time.Time]bool{}}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = c.readJSON(f)
+	} else {
+		err = c.readCSV(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("calendar: %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// readJSON заполняет календарь из JSON.
+func (c *Calendar) readJSON(r io.Reader) error {
+	var file calendarFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return err
+	}
+	for _, s := range file.Holidays {
+		if err := c.add(s, "holiday"); err != nil {
+			return err
+		}
+	}
+	for _, s := range file.Workdays {
+		if err := c.add(s, "workday"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCSV заполняет календарь из CSV.
+func (c *Calendar) readCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		kind := "holiday"
+		if len(rec) > 1 {
+			kind = strings.ToLower(strings.TrimSpace(rec[1]))
+		}
+		if err := c.add(rec[0], kind); err != nil {
+			return err
+		}
+	}
+}
+
+// add добавляет в календарь один день.
+func (c *Calendar) add(s, kind string) error {
+	s = strings.TrimSpace(s)
+	d, err := time.Parse("20060102", s)
+	if err != nil {
+		d, err = time.Parse("2006-01-02", s)
+	}
+	if err != nil {
+		return fmt.Errorf("некорректная дата [%s]", s)
+	}
+
+	switch kind {
+	case "holiday":
+		c.holidays[d] = true
+	case "workday":
+		c.workdays[d] = true
+	default:
+		return fmt.Errorf("неизвестный тип дня [%s] для даты %s, ожидается holiday или workday", kind, s)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package nextdate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(s string) time.Time {
+	d, err := time.Parse("20060102", s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestLoadCalendar(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "ru.json")
+	csvPath := filepath.Join(dir, "ru.csv")
+	assert.NoError(t, os.WriteFile(jsonPath, []byte(`{"holidays": ["20260101", "2026-01-02"], "workdays": ["20260103"]}`), 0o644))
+	assert.NoError(t, os.WriteFile(csvPath, []byte("# праздники\n20260101\n2026-01-02,holiday\n20260103,workday\n"), 0o644))
+
+	for _, path := range []string{jsonPath, csvPath} {
+		c, err := LoadCalendar(path)
+		assert.NoError(t, err, path)
+		assert.False(t, c.IsWorkday(date("20260101")), path)
+		assert.False(t, c.IsWorkday(date("20260102")), path)
+		assert.True(t, c.IsWorkday(date("20260103")), path) // рабочая суббота
+		assert.False(t, c.IsWorkday(date("20260104")), path)
+		assert.True(t, c.IsWorkday(date("20260105")), path)
+	}
+
+	bad := filepath.Join(dir, "bad.csv")
+	assert.NoError(t, os.WriteFile(bad, []byte("20260101,vacation\n"), 0o644))
+	_, err := LoadCalendar(bad)
+	assert.Error(t, err)
+	_, err = LoadCalendar(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestWorkdayAdjustments(t *testing.T) {
+	c := &Calendar{
+		holidays: map[time.Time]bool{date("20260101"): true, date("20260102"): true, date("20260309"): true},
+		workdays: map[time.Time]bool{},
+	}
+	SetCalendar(c)
+	defer SetCalendar(nil)
+
+	now := date("20251225")
+	tbl := []struct {
+		date   string
+		repeat string
+		want   string
+	}{
+		{"20251201", "m 1", "20260101"},
+		{"20251201", "m 1 shift", "20260105"},
+		{"20251201", "m 1 workdays", "20260401"}, // 1 февраля и 1 марта — воскресенья
+		{"20251226", "d 1 workdays", "20251229"},
+		{"20260201", "m 9 3 shift", "20260310"},
+		{"20251201", "m 1 shift until 20260104", ""},
+	}
+	for _, v := range tbl {
+		got, err := NextDate(now, v.date, v.repeat, "done")
+		if v.want == "" {
+			assert.ErrorIs(t, err, ErrNoOccurrences, v.repeat)
+			continue
+		}
+		assert.NoError(t, err, v.repeat)
+		assert.Equal(t, v.want, got, v.repeat)
+	}
+
+	_, err := Parse("d 1 workdays shift")
+	assert.Error(t, err)
+	rule, err := Parse("m 1 shift x3")
+	assert.NoError(t, err)
+	assert.Equal(t, "m 1 shift x3", rule.String())
+	_, err = rule.RRule()
+	assert.Error(t, err)
+}
@@ -0,0 +1,282 @@
+package nextdate
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Поддерживаемые языки описаний правил.
+const (
+	LocaleRU = "ru"
+	LocaleEN = "en"
+)
+
+// Describe возвращает описание правила на человеческом языке, например
+// «on the 1st and last day of February and August» для "m 1,-1 2,8".
+// Неизвестный язык считается русским.
+func Describe(rule RepeatRule, locale string) string {
+	if locale == LocaleEN {
+		return describeEN(rule)
+	}
+	return describeRU(rule)
+}
+
+// LocaleFromRequest определяет язык описания по параметру lang,
+// а если его нет — по заголовку Accept-Language.
+func LocaleFromRequest(r *http.Request) string {
+	lang := r.FormValue("lang")
+	if lang == "" {
+		lang = r.Header.Get("Accept-Language")
+	}
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(lang)), LocaleEN) {
+		return LocaleEN
+	}
+	return LocaleRU
+}
+
+var (
+	weekdaysEN = []string{"", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+	monthsEN   = []string{"", "January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+	nthEN      = map[int]string{1: "first", 2: "second", 3: "third", 4: "fourth", 5: "fifth", -1: "last", -2: "second-to-last", -3: "third-to-last", -4: "fourth-to-last", -5: "fifth-to-last"}
+)
+
+// describeEN строит описание на английском.
+func describeEN(r RepeatRule) string {
+	var s string
+	switch r.Kind {
+	case "":
+		return "does not repeat"
+	case Daily:
+		s = every(r.Interval, "every day", "days")
+	case Weekly:
+		days := make([]string, len(r.Weekdays))
+		for i, wd := range r.Weekdays {
+			days[i] = weekdaysEN[wd]
+		}
+		s = "every " + joinWords(days, "and")
+		if r.Interval > 1 {
+			s = every(r.Interval, "", "weeks") + " on " + joinWords(days, "and")
+		}
+	case Monthly:
+		var days, special []string
+		for _, md := range r.MonthDays {
+			if md < 0 {
+				special = append(special, nthEN[md])
+			} else {
+				days = append(days, ordinalEN(md))
+			}
+		}
+		// «1st and 15th», но «1st and last day»
+		days = append(days, special...)
+		if len(special) > 0 {
+			days[len(days)-1] += " day"
+		}
+		s = "on the " + joinWords(days, "and") + " of " + monthsOfEN(r.Months)
+	case MonthlyByWeekday:
+		days := make([]string, len(r.NthWeekdays))
+		for i, nw := range r.NthWeekdays {
+			days[i] = nthEN[nw.N] + " " + weekdaysEN[nw.Weekday]
+		}
+		s = "on the " + joinWords(days, "and") + " of " + monthsOfEN(r.Months)
+	case Yearly:
+		s = every(r.Interval, "every year", "years")
+	case Hourly:
+		s = every(r.Interval, "every hour", "hours")
+	case Minutely:
+		s = every(r.Interval, "every minute", "minutes")
+	}
+
+	switch r.Adjust {
+	case SkipHolidays:
+		s += ", workdays only"
+	case ShiftToWorkday:
+		s += ", moved to the next workday if it falls on a day off"
+	}
+	if !r.Until.IsZero() {
+		s += ", until " + r.Until.Format("January 2, 2006")
+	}
+	if r.Count == 1 {
+		s += ", once"
+	} else if r.Count > 1 {
+		s += ", " + strconv.Itoa(r.Count) + " times"
+	}
+	return s
+}
+
+// every возвращает «every day» для n = 1 и «every 3 days» для остальных.
+func every(n int, one, many string) string {
+	if n <= 1 && one != "" {
+		return one
+	}
+	return "every " + strconv.Itoa(n) + " " + many
+}
+
+// ordinalEN возвращает порядковое числительное: 1st, 2nd, 3rd, 11th, 22nd.
+func ordinalEN(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// monthsOfEN перечисляет месяцы или возвращает «every month».
+func monthsOfEN(months []int) string {
+	if len(months) == 0 {
+		return "every month"
+	}
+	names := make([]string, len(months))
+	for i, m := range months {
+		names[i] = monthsEN[m]
+	}
+	return joinWords(names, "and")
+}
+
+var (
+	// дни недели в винительном падеже и их род: m, f или n
+	weekdaysRU       = []string{"", "понедельник", "вторник", "среду", "четверг", "пятницу", "субботу", "воскресенье"}
+	weekdayGenderRU  = []byte{0, 'm', 'm', 'f', 'm', 'f', 'f', 'n'}
+	weekdaysPluralRU = []string{"", "понедельникам", "вторникам", "средам", "четвергам", "пятницам", "субботам", "воскресеньям"}
+	monthsRU         = []string{"", "января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"}
+
+	// порядковые числительные в винительном падеже по родам
+	nthRU = map[int][3]string{
+		1:  {"первый", "первую", "первое"},
+		2:  {"второй", "вторую", "второе"},
+		3:  {"третий", "третью", "третье"},
+		4:  {"четвёртый", "четвёртую", "четвёртое"},
+		5:  {"пятый", "пятую", "пятое"},
+		-1: {"последний", "последнюю", "последнее"},
+		-2: {"предпоследний", "предпоследнюю", "предпоследнее"},
+		-3: {"третий с конца", "третью с конца", "третье с конца"},
+		-4: {"четвёртый с конца", "четвёртую с конца", "четвёртое с конца"},
+		-5: {"пятый с конца", "пятую с конца", "пятое с конца"},
+	}
+)
+
+// describeRU строит описание на русском.
+func describeRU(r RepeatRule) string {
+	var s string
+	switch r.Kind {
+	case "":
+		return "не повторяется"
+	case Daily:
+		s = everyRU(r.Interval, "каждый день", "день", "дня", "дней")
+	case Weekly:
+		days := make([]string, len(r.Weekdays))
+		for i, wd := range r.Weekdays {
+			days[i] = weekdaysPluralRU[wd]
+		}
+		s = "по " + joinWords(days, "и")
+		if r.Interval > 1 {
+			s = everyRU(r.Interval, "", "неделю", "недели", "недель") + " " + s
+		}
+	case Monthly:
+		var numbers, special []string
+		for _, md := range r.MonthDays {
+			switch md {
+			case -1:
+				special = append(special, "в последний день")
+			case -2:
+				special = append(special, "в предпоследний день")
+			default:
+				numbers = append(numbers, strconv.Itoa(md)+"-го")
+			}
+		}
+		parts := special
+		if len(numbers) > 0 {
+			parts = append([]string{joinWords(numbers, "и") + " числа"}, special...)
+		}
+		s = joinWords(parts, "и") + " " + monthsOfRU(r.Months)
+	case MonthlyByWeekday:
+		days := make([]string, len(r.NthWeekdays))
+		for i, nw := range r.NthWeekdays {
+			gender := strings.IndexByte("mfn", weekdayGenderRU[nw.Weekday])
+			days[i] = prepositionRU(nthRU[nw.N][gender]) + " " + weekdaysRU[nw.Weekday]
+		}
+		s = joinWords(days, "и") + " " + monthsOfRU(r.Months)
+	case Yearly:
+		s = everyRU(r.Interval, "каждый год", "год", "года", "лет")
+	case Hourly:
+		s = everyRU(r.Interval, "каждый час", "час", "часа", "часов")
+	case Minutely:
+		s = everyRU(r.Interval, "каждую минуту", "минуту", "минуты", "минут")
+		// «минута» женского рода: «каждую 21 минуту»
+		s = strings.Replace(s, "каждый ", "каждую ", 1)
+	}
+
+	switch r.Adjust {
+	case SkipHolidays:
+		s += ", только в рабочие дни"
+	case ShiftToWorkday:
+		s += ", с переносом на следующий рабочий день"
+	}
+	if !r.Until.IsZero() {
+		s += ", до " + r.Until.Format("02.01.2006")
+	}
+	if r.Count > 0 {
+		s += ", " + strconv.Itoa(r.Count) + " " + pluralRU(r.Count, "раз", "раза", "раз")
+	}
+	return s
+}
+
+// everyRU возвращает «каждый день» для n = 1, «каждые 3 дня», «каждые 5 дней»
+// и «каждый 21 день» для остальных.
+func everyRU(n int, one, form1, form2, form5 string) string {
+	if n <= 1 && one != "" {
+		return one
+	}
+	each := "каждые"
+	if n%10 == 1 && n%100 != 11 {
+		each = "каждый"
+	}
+	return each + " " + strconv.Itoa(n) + " " + pluralRU(n, form1, form2, form5)
+}
+
+// pluralRU выбирает форму слова для числа n: 1 день, 2 дня, 5 дней.
+func pluralRU(n int, form1, form2, form5 string) string {
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return form1
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return form2
+	default:
+		return form5
+	}
+}
+
+// prepositionRU добавляет предлог «в» или «во» («во второй вторник»).
+func prepositionRU(word string) string {
+	if strings.HasPrefix(word, "вт") {
+		return "во " + word
+	}
+	return "в " + word
+}
+
+// monthsOfRU перечисляет месяцы в родительном падеже или возвращает «каждого месяца».
+func monthsOfRU(months []int) string {
+	if len(months) == 0 {
+		return "каждого месяца"
+	}
+	names := make([]string, len(months))
+	for i, m := range months {
+		names[i] = monthsRU[m]
+	}
+	return joinWords(names, "и")
+}
+
+// joinWords перечисляет слова через запятую, последнее — через союз: «A, B и C».
+func joinWords(words []string, and string) string {
+	if len(words) <= 1 {
+		return strings.Join(words, "")
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " " + and + " " + words[len(words)-1]
+}
@@ -0,0 +1,44 @@
+package nextdate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribe(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		en     string
+		ru     string
+	}{
+		{"", "does not repeat", "не повторяется"},
+		{"d 1", "every day", "каждый день"},
+		{"d 3", "every 3 days", "каждые 3 дня"},
+		{"d 21", "every 21 days", "каждый 21 день"},
+		{"d 12", "every 12 days", "каждые 12 дней"},
+		{"w 1,3,5", "every Monday, Wednesday and Friday", "по понедельникам, средам и пятницам"},
+		{"w 1 2", "every 2 weeks on Monday", "каждые 2 недели по понедельникам"},
+		{"m 1,-1 2,8", "on the 1st and last day of February and August", "1-го числа и в последний день февраля и августа"},
+		{"m 1,15", "on the 1st and 15th of every month", "1-го и 15-го числа каждого месяца"},
+		{"m -2", "on the second-to-last day of every month", "в предпоследний день каждого месяца"},
+		{"mw 2:2", "on the second Tuesday of every month", "во второй вторник каждого месяца"},
+		{"mw -1:5,1:3 3,9", "on the last Friday and first Wednesday of March and September", "в последнюю пятницу и в первую среду марта и сентября"},
+		{"y", "every year", "каждый год"},
+		{"y 5", "every 5 years", "каждые 5 лет"},
+		{"d 1 workdays", "every day, workdays only", "каждый день, только в рабочие дни"},
+		{"m 1 shift until 20261231", "on the 1st of every month, moved to the next workday if it falls on a day off, until December 31, 2026", "1-го числа каждого месяца, с переносом на следующий рабочий день, до 31.12.2026"},
+		{"w 7 x3", "every Sunday, 3 times", "по воскресеньям, 3 раза"},
+		{"h 1", "every hour", "каждый час"},
+		{"h 3", "every 3 hours", "каждые 3 часа"},
+		{"min 1", "every minute", "каждую минуту"},
+		{"min 21", "every 21 minutes", "каждую 21 минуту"},
+		{"min 45", "every 45 minutes", "каждые 45 минут"},
+	}
+	for _, v := range tbl {
+		rule, err := Parse(v.repeat)
+		assert.NoError(t, err, v.repeat)
+		assert.Equal(t, v.en, Describe(rule, LocaleEN), v.repeat)
+		assert.Equal(t, v.ru, Describe(rule, LocaleRU), v.repeat)
+	}
+}
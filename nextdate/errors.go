@@ -0,0 +1,129 @@
+package nextdate
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Коды ошибок разбора правила повторения.
+const (
+	CodeUnknownKind    = "unknown_kind"    // неизвестный вид правила
+	CodeMissingValue   = "missing_value"   // не хватает значения
+	CodeExtraValue     = "extra_value"     // лишнее или неизвестное значение
+	CodeNotANumber     = "not_a_number"    // ожидалось число
+	CodeOutOfRange     = "out_of_range"    // число вне допустимого диапазона
+	CodeBadFormat      = "bad_format"      // значение записано в неверном формате
+	CodeBadDate        = "bad_date"        // дата не в формате YYYYMMDD
+	CodeImpossibleDate = "impossible_date" // правило не описывает ни одной существующей даты
+	CodeConflict       = "conflict"        // модификатор повторён или противоречит другому
+	CodeUnsupported    = "unsupported"     // конструкция RRULE не поддерживается
+	CodeInvalid        = "invalid"         // прочие ошибки, не привязанные к месту в правиле
+)
+
+// RuleError — ошибка разбора правила повторения с указанием ошибочного места.
+// Достаётся из цепочки ошибок через errors.As.
+type RuleError struct {
+	Code   string // один из кодов Code*
+	Token  string // ошибочный фрагмент правила, пустой — если значения не хватает
+	Pos    int    // позиция фрагмента в символах от начала строки, -1 — неизвестна
+	Min    int    // допустимый диапазон, заполняется для CodeOutOfRange
+	Max    int
+	Detail string // описание ошибки для человека
+}
+
+func (e *RuleError) Error() string {
+	if e.Token == "" {
+		return "nextDate: " + e.Detail
+	}
+	return fmt.Sprintf("nextDate: %s: [%s]", e.Detail, e.Token)
+}
+
+// HasRange сообщает, что для ошибки известен допустимый диапазон Min..Max.
+func (e *RuleError) HasRange() bool {
+	return e.Code == CodeOutOfRange
+}
+
+// FieldError — ошибка в правиле повторения в виде, пригодном для ответа API:
+// по коду, позиции и диапазону интерфейс может подсветить ошибочную часть правила.
+type FieldError struct {
+	Code     string `json:"code"`
+	Field    string `json:"field"` // поле запроса с правилом: repeat или rrule
+	Detail   string `json:"detail"`
+	Token    string `json:"token,omitempty"`
+	Position *int   `json:"position,omitempty"` // в символах от начала поля
+	Min      *int   `json:"min,omitempty"`
+	Max      *int   `json:"max,omitempty"`
+}
+
+// FieldErrorOf переводит ошибку разбора правила из поля field в FieldError.
+// Ошибки, не являющиеся *RuleError, получают код CodeInvalid.
+func FieldErrorOf(field string, err error) FieldError {
+	var re *RuleError
+	if !errors.As(err, &re) {
+		return FieldError{Code: CodeInvalid, Field: field, Detail: err.Error()}
+	}
+	fe := FieldError{Code: re.Code, Field: field, Detail: re.Detail, Token: re.Token}
+	if re.Pos >= 0 {
+		fe.Position = &re.Pos
+	}
+	if re.HasRange() {
+		fe.Min, fe.Max = &re.Min, &re.Max
+	}
+	return fe
+}
+
+// token — слово правила и его позиция в символах в исходной строке.
+type token struct {
+	text string
+	pos  int
+}
+
+// tokenize разбивает строку на слова по пробелам, запоминая их позиции.
+func tokenize(s string) []token {
+	var (
+		tokens []token
+		cur    []rune
+		start  int
+	)
+	pos := 0
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if len(cur) > 0 {
+				tokens = append(tokens, token{text: string(cur), pos: start})
+				cur = cur[:0]
+			}
+		} else {
+			if len(cur) == 0 {
+				start = pos
+			}
+			cur = append(cur, r)
+		}
+		pos++
+	}
+	if len(cur) > 0 {
+		tokens = append(tokens, token{text: string(cur), pos: start})
+	}
+	return tokens
+}
+
+// sub возвращает часть токена text, начинающуюся через offset байт от его начала.
+func (t token) sub(offset int, text string) token {
+	return token{text: text, pos: t.pos + utf8.RuneCountInString(t.text[:offset])}
+}
+
+// end возвращает пустой токен сразу за t — туда указывает ошибка о недостающем значении.
+func (t token) end() token {
+	return token{pos: t.pos + utf8.RuneCountInString(t.text)}
+}
+
+// ruleErr создаёт ошибку разбора для токена t.
+func ruleErr(code string, t token, detail string) *RuleError {
+	return &RuleError{Code: code, Token: t.text, Pos: t.pos, Detail: detail}
+}
+
+// rangeErr создаёт ошибку «число вне диапазона» для токена t.
+func rangeErr(t token, min, max int, detail string) *RuleError {
+	return &RuleError{Code: CodeOutOfRange, Token: t.text, Pos: t.pos, Min: min, Max: max, Detail: detail}
+}
@@ -0,0 +1,189 @@
+package nextdate
+
+import (
+	"encoding/json"
+	"iter"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/naluneotlichno/FP-GO-API/clock"
+)
+
+const (
+	defaultOccurrences = 10   // сколько дат отдаём, если count не указан
+	maxOccurrences     = 1000 // верхняя граница для count и для окна from..to
+)
+
+// OccurrencesResponse — ответ /api/nextdate/occurrences
+type OccurrencesResponse struct {
+	Dates       []string `json:"dates"`
+	Description string   `json:"description"`
+}
+
+// Occurrences перечисляет даты повторения задачи с датой start по правилу rule,
+// идущие строго после from; start и from сравниваются вместе со временем, и время start
+// сохраняется во всех датах. count ограничивает количество дат, 0 — без ограничения:
+// тогда остановить перебор должен вызывающий код.
+// Первым из повторений считается сама дата start, как DTSTART в RFC 5545; для пустого
+// правила оно единственное. Даты из Except не выдаются, но в Count правила засчитываются,
+// как EXDATE.
+func Occurrences(start time.Time, rule RepeatRule, from time.Time, count int) iter.Seq[time.Time] {
+	return func(yield func(time.Time) bool) {
+		if rule.IsZero() {
+			if start.After(from) {
+				yield(start)
+			}
+			return
+		}
+
+		n := 0
+		emit := func(d time.Time) bool {
+			if !d.After(from) || slices.Contains(rule.Except, dateOf(d)) {
+				return true
+			}
+			if !yield(d) {
+				return false
+			}
+			n++
+			return count <= 0 || n < count
+		}
+
+		if rule.Count > 0 {
+			plain := rule
+			plain.Except = nil
+			cur := start
+			for left := rule.Count; ; {
+				if !emit(cur) {
+					return
+				}
+				if left--; left == 0 {
+					return
+				}
+				next, err := plain.Next(cur, start)
+				if err != nil {
+					return
+				}
+				cur = next
+			}
+		}
+
+		if !emit(start) {
+			return
+		}
+		cur := from
+		if start.After(cur) {
+			cur = start
+		}
+		for {
+			next, err := rule.Next(cur, start)
+			if err != nil || !emit(next) {
+				return
+			}
+			cur = next
+		}
+	}
+}
+
+// 🔥 OccurrencesHandler возвращает обработчик запросов на /api/nextdate/occurrences.
+// Параметры: date и repeat — как у /api/nextdate; time — время начала HH:MM;
+// from — дата отсчёта (по умолчанию now или сегодняшняя дата по часам c);
+// count — сколько дат вернуть; to — если задан, возвращаются все даты в окне (from, to],
+// а если их там больше maxOccurrences, запрос отклоняется, чтобы не отдать окно не целиком;
+// except — даты-исключения YYYYMMDD через запятую.
+// Если указано время или правило h/min, даты отдаются в формате DateTimeLayout.
+func OccurrencesHandler(c clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handleOccurrences(w, r, c.Now())
+	}
+}
+
+// handleOccurrences обрабатывает запрос на список дат повторения.
+func handleOccurrences(w http.ResponseWriter, r *http.Request, now time.Time) {
+	log.Println("✅ Запрос на список дат повторения получен!")
+
+	layout, date := "20060102", r.FormValue("date")
+	if v := r.FormValue("time"); v != "" {
+		layout, date = DateTimeLayout, date+" "+v
+	}
+	start, err := time.Parse(layout, date)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Некорректная дата date или время time"})
+		return
+	}
+
+	rule, err := Parse(r.FormValue("repeat"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": FieldErrorOf("repeat", err)})
+		return
+	}
+	if v := r.FormValue("except"); v != "" {
+		if rule.Except, err = ParseDates(strings.Split(v, ",")); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Некорректная дата в except"})
+			return
+		}
+	}
+
+	from := now
+	for _, name := range []string{"now", "from"} {
+		if v := r.FormValue(name); v != "" {
+			from, err = time.Parse("20060102", v)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Некорректная дата " + name})
+				return
+			}
+		}
+	}
+
+	count := defaultOccurrences
+	if v := r.FormValue("count"); v != "" {
+		count, err = strconv.Atoi(v)
+		if err != nil || count < 1 || count > maxOccurrences {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "count должен быть между 1 и " + strconv.Itoa(maxOccurrences)})
+			return
+		}
+	}
+
+	var to time.Time
+	if v := r.FormValue("to"); v != "" {
+		to, err = time.Parse("20060102", v)
+		if err != nil || to.Before(dateOf(from)) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Некорректная дата to"})
+			return
+		}
+		// Одна дата сверх предела показывает, что окно в ответ целиком не поместится
+		count = maxOccurrences + 1
+	}
+
+	if rule.SubDaily() {
+		layout = DateTimeLayout
+	}
+	dates := []string{}
+	for d := range Occurrences(start, rule, from, count) {
+		if !to.IsZero() && dateOf(d).After(to) {
+			break
+		}
+		dates = append(dates, d.Format(layout))
+	}
+	if len(dates) > maxOccurrences {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "В окне from..to больше " + strconv.Itoa(maxOccurrences) + " дат, укажите окно короче"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, OccurrencesResponse{
+		Dates:       dates,
+		Description: Describe(rule, LocaleFromRequest(r)),
+	})
+}
+
+// writeJSON отправляет ответ в формате JSON
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		log.Printf("❌ [writeJSON] Ошибка кодирования JSON: %v", err)
+	}
+}
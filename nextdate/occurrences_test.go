@@ -0,0 +1,124 @@
+package nextdate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/naluneotlichno/FP-GO-API/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOccurrences(t *testing.T) {
+	from := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rule, err := Parse("m -1,15 1,6")
+	assert.NoError(t, err)
+
+	var got []string
+	for d := range Occurrences(start, rule, from, 4) {
+		got = append(got, d.Format("20060102"))
+	}
+	assert.Equal(t, []string{"20240131", "20240615", "20240630", "20250115"}, got)
+
+	got = nil
+	for d := range Occurrences(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), RepeatRule{}, from, 5) {
+		got = append(got, d.Format("20060102"))
+	}
+	assert.Equal(t, []string{"20240201"}, got)
+}
+
+func TestOccurrencesWithTime(t *testing.T) {
+	start := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	list := func(repeat string, from time.Time, count int) []string {
+		t.Helper()
+		rule, err := Parse(repeat)
+		assert.NoError(t, err)
+		var got []string
+		for d := range Occurrences(start, rule, from, count) {
+			got = append(got, d.Format(DateTimeLayout))
+		}
+		return got
+	}
+
+	// Сама дата start — первое повторение, с правилом xN и без него, и время у всех дат одно
+	before := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{"20260105 10:00", "20260112 10:00", "20260119 10:00"}, list("d 7 x3", before, 0))
+	assert.Equal(t, []string{"20260105 10:00", "20260112 10:00", "20260119 10:00"}, list("d 7", before, 3))
+
+	// from сравнивается вместе со временем
+	assert.Equal(t, []string{"20260105 10:00", "20260112 10:00"}, list("d 7 x3", start.Add(-time.Hour), 2))
+	assert.Equal(t, []string{"20260112 10:00", "20260119 10:00"}, list("d 7 x3", start, 0))
+	assert.Equal(t, []string{"20260112 10:00", "20260119 10:00"}, list("d 7", start, 2))
+
+	// Правило внутри суток отсчитывает часы от времени start, а не от полуночи
+	assert.Equal(t, []string{"20260105 10:00", "20260105 12:00", "20260105 14:00"}, list("h 2 x3", before, 0))
+	assert.Equal(t, []string{"20260105 12:00", "20260105 14:00"}, list("h 2 x3", start, 0))
+	assert.Equal(t, []string{"20260105 10:00", "20260105 12:00", "20260105 14:00"}, list("h 2", before, 3))
+}
+
+func TestHandleOccurrences(t *testing.T) {
+	now := time.Date(2024, 1, 26, 23, 59, 59, 0, time.UTC)
+	get := func(query string) (int, map[string]any) {
+		rec := httptest.NewRecorder()
+		OccurrencesHandler(clock.Fixed(now))(rec, httptest.NewRequest(http.MethodGet, "/api/nextdate/occurrences?"+query, nil))
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m))
+		return rec.Code, m
+	}
+
+	code, m := get("now=20240126&date=20240113&repeat=d+7&count=3")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []any{"20240127", "20240203", "20240210"}, m["dates"])
+
+	// Без now и from отсчёт идёт от сегодняшней даты по часам сервера
+	code, m = get("date=20240113&repeat=d+7&count=2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []any{"20240127", "20240203"}, m["dates"])
+
+	code, m = get("from=20240126&to=20240210&date=20240125&repeat=w+6")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []any{"20240127", "20240203", "20240210"}, m["dates"])
+
+	code, m = get("now=20240126&date=20240113&repeat=d+7&count=2&except=20240127")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []any{"20240203", "20240210"}, m["dates"])
+
+	code, m = get("now=20240101&date=20240105&time=10:00&repeat=d+7+x3")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []any{"20240105 10:00", "20240112 10:00", "20240119 10:00"}, m["dates"])
+
+	code, m = get("now=20240101&date=20240105&time=10:00&repeat=h+2+x3")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []any{"20240105 10:00", "20240105 12:00", "20240105 14:00"}, m["dates"])
+
+	// Окно ровно на предел отдаётся целиком, больше — не отдаётся вовсе
+	code, m = get("from=20240101&to=20260927&date=20240101&repeat=d+1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, m["dates"], 1000)
+	assert.Contains(t, m["dates"], "20260927")
+
+	for _, q := range []string{
+		"date=20240101&repeat=d+1&from=20240101&to=20260928",
+		"date=20240101&time=00:00&repeat=min+1&from=20240101&to=20240101",
+		"date=ooops&repeat=d+1",
+		"date=20240101&repeat=k+1",
+		"date=20240101&repeat=d+1&count=0",
+		"date=20240101&repeat=d+1&from=20240201&to=20240101",
+		"date=20240101&repeat=d+1&except=2024-01-02",
+	} {
+		code, m = get(q)
+		assert.Equal(t, http.StatusBadRequest, code, q)
+		assert.NotEmpty(t, m["error"], q)
+	}
+
+	code, m = get("date=20240101&repeat=w+1,8")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, map[string]any{
+		"code": CodeOutOfRange, "field": "repeat", "detail": "некорректный день недели",
+		"token": "8", "position": 4.0, "min": 1.0, "max": 7.0,
+	}, m["error"])
+}
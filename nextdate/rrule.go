@@ -0,0 +1,253 @@
+package nextdate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// rruleDays — коды дней недели RFC 5545 в нашей нумерации: MO — 1, SU — 7.
+var rruleDays = []string{"", "MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+// RRule переводит правило в строку RRULE по RFC 5545, например
+// "FREQ=WEEKLY;BYDAY=MO,WE". Пустое правило даёт пустую строку.
+func (r RepeatRule) RRule() (string, error) {
+	var parts []string
+	switch r.Kind {
+	case "":
+		return "", nil
+	case Daily:
+		parts = append(parts, "FREQ=DAILY", "INTERVAL="+strconv.Itoa(r.Interval))
+	case Weekly:
+		days := make([]string, len(r.Weekdays))
+		for i, wd := range r.Weekdays {
+			days[i] = rruleDays[wd]
+		}
+		parts = append(parts, "FREQ=WEEKLY", "BYDAY="+strings.Join(days, ","))
+		if r.Interval > 1 {
+			parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+		}
+	case Monthly:
+		parts = append(parts, "FREQ=MONTHLY", "BYMONTHDAY="+joinInts(r.MonthDays))
+		if len(r.Months) > 0 {
+			parts = append(parts, "BYMONTH="+joinInts(r.Months))
+		}
+	case MonthlyByWeekday:
+		days := make([]string, len(r.NthWeekdays))
+		for i, nw := range r.NthWeekdays {
+			days[i] = strconv.Itoa(nw.N) + rruleDays[nw.Weekday]
+		}
+		parts = append(parts, "FREQ=MONTHLY", "BYDAY="+strings.Join(days, ","))
+		if len(r.Months) > 0 {
+			parts = append(parts, "BYMONTH="+joinInts(r.Months))
+		}
+	case Yearly:
+		parts = append(parts, "FREQ=YEARLY")
+		if r.Interval > 1 {
+			parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+		}
+	case Hourly:
+		parts = append(parts, "FREQ=HOURLY", "INTERVAL="+strconv.Itoa(r.Interval))
+	case Minutely:
+		parts = append(parts, "FREQ=MINUTELY", "INTERVAL="+strconv.Itoa(r.Interval))
+	default:
+		return "", fmt.Errorf("rrule: правило [%s] нельзя выразить через RRULE", r)
+	}
+
+	if r.Adjust != "" {
+		return "", fmt.Errorf("rrule: модификатор %s нельзя выразить через RRULE: [%s]", r.Adjust, r)
+	}
+	if !r.Until.IsZero() && r.Count > 0 {
+		return "", fmt.Errorf("rrule: RFC 5545 не допускает одновременно UNTIL и COUNT: [%s]", r)
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	return strings.Join(parts, ";"), nil
+}
+
+// ParseRRule разбирает строку RRULE (с префиксом "RRULE:" или без него)
+// и возвращает эквивалентное правило. Поддерживается подмножество RFC 5545,
+// которое выражается правилами d, w, m, mw, y, h и min; остальное даёт ошибку.
+// Ошибка разбора имеет тип *RuleError, позиция считается от начала s.
+func ParseRRule(s string) (RepeatRule, error) {
+	body := strings.TrimSpace(s)
+	if body == "" {
+		return RepeatRule{}, nil
+	}
+	offset := utf8.RuneCountInString(s[:strings.Index(s, body)])
+	if strings.HasPrefix(body, "RRULE:") {
+		body = body[len("RRULE:"):]
+		offset += len("RRULE:")
+	}
+	if body == "" {
+		return RepeatRule{}, nil
+	}
+	whole := token{text: body, pos: offset}
+
+	params := map[string]token{}
+	parts := map[string]token{}
+	pos := 0
+	for _, part := range strings.Split(body, ";") {
+		pt := whole.sub(pos, part)
+		pos += len(part) + 1
+
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		if !ok || key == "" || value == "" {
+			return RepeatRule{}, ruleErr(CodeBadFormat, pt, "некорректная часть, ожидается ПАРАМЕТР=ЗНАЧЕНИЕ")
+		}
+		if _, dup := params[key]; dup {
+			return RepeatRule{}, ruleErr(CodeConflict, pt, "параметр "+key+" указан дважды")
+		}
+		params[key] = pt.sub(len(part)-len(value), strings.ToUpper(value))
+		parts[key] = pt
+	}
+
+	take := func(key string) token {
+		v := params[key]
+		delete(params, key)
+		return v
+	}
+
+	interval := 1
+	if v := take("INTERVAL"); v.text != "" {
+		n, err := strconv.Atoi(v.text)
+		if err != nil {
+			return RepeatRule{}, ruleErr(CodeNotANumber, v, "INTERVAL должен быть числом")
+		}
+		if n < 1 {
+			return RepeatRule{}, rangeErr(v, 1, 400, "INTERVAL должен быть положительным")
+		}
+		interval = n
+	}
+	// Неделя у нас всегда начинается с понедельника
+	if v := take("WKST"); v.text != "" && v.text != "MO" {
+		return RepeatRule{}, ruleErr(CodeUnsupported, v, "поддерживается только WKST=MO")
+	}
+
+	var repeat []string
+	freqTok, byDayTok := take("FREQ"), take("BYDAY")
+	freq, byDay, byMonthDay, byMonth := freqTok.text, byDayTok.text, take("BYMONTHDAY").text, take("BYMONTH").text
+	switch {
+	case freq == "":
+		return RepeatRule{}, ruleErr(CodeMissingValue, whole.end(), "не указан параметр FREQ")
+
+	case freq == "DAILY" && byDay == "" && byMonthDay == "" && byMonth == "":
+		repeat = []string{"d", strconv.Itoa(interval)}
+
+	case freq == "WEEKLY" && byDay != "" && byMonthDay == "" && byMonth == "":
+		days, err := rruleWeekdays(byDayTok)
+		if err != nil {
+			return RepeatRule{}, err
+		}
+		repeat = []string{"w", days, strconv.Itoa(interval)}
+
+	case (freq == "MONTHLY" || freq == "YEARLY" && byMonth != "") && interval == 1 && byMonthDay != "" && byDay == "":
+		repeat = []string{"m", byMonthDay}
+		if byMonth != "" {
+			repeat = append(repeat, byMonth)
+		}
+
+	case (freq == "MONTHLY" || freq == "YEARLY" && byMonth != "") && interval == 1 && byDay != "" && byMonthDay == "":
+		days, err := rruleNthWeekdays(byDayTok)
+		if err != nil {
+			return RepeatRule{}, err
+		}
+		repeat = []string{"mw", days}
+		if byMonth != "" {
+			repeat = append(repeat, byMonth)
+		}
+
+	case freq == "YEARLY" && byDay == "" && byMonthDay == "" && byMonth == "":
+		repeat = []string{"y", strconv.Itoa(interval)}
+
+	case freq == "HOURLY" && byDay == "" && byMonthDay == "" && byMonth == "":
+		repeat = []string{"h", strconv.Itoa(interval)}
+
+	case freq == "MINUTELY" && byDay == "" && byMonthDay == "" && byMonth == "":
+		repeat = []string{"min", strconv.Itoa(interval)}
+
+	default:
+		return RepeatRule{}, ruleErr(CodeUnsupported, whole, "сочетание параметров не поддерживается")
+	}
+
+	if v := take("UNTIL"); v.text != "" {
+		// UNTIL может содержать время: 20261231T235959Z — нас интересует только дата
+		date, _, _ := strings.Cut(v.text, "T")
+		if _, err := time.Parse("20060102", date); err != nil {
+			return RepeatRule{}, ruleErr(CodeBadDate, v, "некорректный UNTIL, ожидается YYYYMMDD")
+		}
+		repeat = append(repeat, "until", date)
+	}
+	if v := take("COUNT"); v.text != "" {
+		n, err := parseNumber(v, 1, maxCount, "COUNT")
+		if err != nil {
+			return RepeatRule{}, err
+		}
+		repeat = append(repeat, "x"+strconv.Itoa(n))
+	}
+
+	if len(params) > 0 {
+		keys := make([]string, 0, len(params))
+		for k := range params {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		return RepeatRule{}, ruleErr(CodeUnsupported, parts[keys[0]], "параметры не поддерживаются: "+strings.Join(keys, ", "))
+	}
+
+	// Границы значений проверяет обычный разбор правила. Позиции его ошибок
+	// относятся к переведённому правилу, а не к RRULE, поэтому сбрасываются.
+	rule, err := Parse(strings.Join(repeat, " "))
+	var re *RuleError
+	if errors.As(err, &re) {
+		re.Pos = -1
+	}
+	return rule, err
+}
+
+// rruleWeekdays переводит BYDAY=MO,WE в список "1,3".
+func rruleWeekdays(byDay token) (string, error) {
+	var days []string
+	offset := 0
+	for _, code := range strings.Split(byDay.text, ",") {
+		item := byDay.sub(offset, code)
+		offset += len(code) + 1
+
+		wd := slices.Index(rruleDays, code)
+		if wd < 1 {
+			return "", ruleErr(CodeBadFormat, item, "некорректный день недели в BYDAY")
+		}
+		days = append(days, strconv.Itoa(wd))
+	}
+	return strings.Join(days, ","), nil
+}
+
+// rruleNthWeekdays переводит BYDAY=2TU,-1FR в список "2:2,-1:5".
+func rruleNthWeekdays(byDay token) (string, error) {
+	var days []string
+	offset := 0
+	for _, code := range strings.Split(byDay.text, ",") {
+		item := byDay.sub(offset, code)
+		offset += len(code) + 1
+
+		if len(code) < 3 {
+			return "", ruleErr(CodeBadFormat, item, "в BYDAY для FREQ=MONTHLY нужен номер дня недели")
+		}
+		n, err := strconv.Atoi(code[:len(code)-2])
+		wd := slices.Index(rruleDays, code[len(code)-2:])
+		if err != nil || wd < 1 {
+			return "", ruleErr(CodeBadFormat, item, "некорректный день недели в BYDAY")
+		}
+		days = append(days, strconv.Itoa(n)+":"+strconv.Itoa(wd))
+	}
+	return strings.Join(days, ","), nil
+}
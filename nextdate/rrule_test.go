@@ -0,0 +1,92 @@
+package nextdate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRRuleRoundTrip(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		rrule  string
+	}{
+		{"", ""},
+		{"d 7", "FREQ=DAILY;INTERVAL=7"},
+		{"w 1,3", "FREQ=WEEKLY;BYDAY=MO,WE"},
+		{"w 5 2", "FREQ=WEEKLY;BYDAY=FR;INTERVAL=2"},
+		{"m -1,15 1,6", "FREQ=MONTHLY;BYMONTHDAY=-1,15;BYMONTH=1,6"},
+		{"mw -1:5,2:2", "FREQ=MONTHLY;BYDAY=-1FR,2TU"},
+		{"y", "FREQ=YEARLY"},
+		{"y 3 x4", "FREQ=YEARLY;INTERVAL=3;COUNT=4"},
+		{"d 1 until 20261231", "FREQ=DAILY;INTERVAL=1;UNTIL=20261231"},
+		{"h 3", "FREQ=HOURLY;INTERVAL=3"},
+		{"min 30 x5", "FREQ=MINUTELY;INTERVAL=30;COUNT=5"},
+	}
+	for _, v := range tbl {
+		rule, err := Parse(v.repeat)
+		assert.NoError(t, err, v.repeat)
+		got, err := rule.RRule()
+		assert.NoError(t, err, v.repeat)
+		assert.Equal(t, v.rrule, got, v.repeat)
+
+		back, err := ParseRRule(got)
+		assert.NoError(t, err, v.rrule)
+		assert.Equal(t, v.repeat, back.String(), v.rrule)
+	}
+}
+
+func TestParseRRule(t *testing.T) {
+	tbl := []struct {
+		rrule  string
+		repeat string
+	}{
+		{"RRULE:FREQ=WEEKLY;BYDAY=WE,MO", "w 1,3"},
+		{"freq=weekly;byday=mo;wkst=mo", "w 1"},
+		{"FREQ=DAILY", "d 1"},
+		{"FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=8", "m 8 3"},
+		{"FREQ=YEARLY;BYMONTH=9;BYDAY=-1FR", "mw -1:5 9"},
+		{"FREQ=MONTHLY;BYDAY=+2TU;UNTIL=20261231T235959Z", "mw 2:2 until 20261231"},
+	}
+	for _, v := range tbl {
+		rule, err := ParseRRule(v.rrule)
+		assert.NoError(t, err, v.rrule)
+		assert.Equal(t, v.repeat, rule.String(), v.rrule)
+	}
+
+	for _, rrule := range []string{
+		"FREQ=SECONDLY",
+		"FREQ=HOURLY;INTERVAL=25",
+		"FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL=401",
+		"FREQ=MONTHLY;INTERVAL=2;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYMONTHDAY=-3",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=MO;BYSETPOS=1",
+		"FREQ=WEEKLY;BYDAY=MO;WKST=SU",
+		"FREQ=DAILY;FREQ=DAILY",
+		"FREQ",
+	} {
+		_, err := ParseRRule(rrule)
+		assert.Error(t, err, rrule)
+	}
+
+	rule, err := Parse("d 1 until 20261231 x3")
+	assert.NoError(t, err)
+	_, err = rule.RRule()
+	assert.Error(t, err)
+
+	// Позиция ошибки считается от начала исходной строки, включая префикс
+	var re *RuleError
+	_, err = ParseRRule("RRULE:FREQ=WEEKLY;BYDAY=MO,XX")
+	if assert.ErrorAs(t, err, &re) {
+		assert.Equal(t, CodeBadFormat, re.Code)
+		assert.Equal(t, "XX", re.Token)
+		assert.Equal(t, 27, re.Pos)
+	}
+	_, err = ParseRRule("FREQ=DAILY;INTERVAL=401")
+	if assert.ErrorAs(t, err, &re) {
+		assert.Equal(t, CodeOutOfRange, re.Code)
+		assert.Equal(t, -1, re.Pos)
+	}
+}
@@ -0,0 +1,592 @@
+package nextdate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind определяет вид правила повторения.
+type Kind string
+
+const (
+	Daily   Kind = "d" // каждые N дней
+	Weekly  Kind = "w" // по дням недели
+	Monthly Kind = "m" // по дням месяца
+	Yearly  Kind = "y" // раз в год
+
+	MonthlyByWeekday Kind = "mw" // по n-му дню недели месяца: «второй вторник», «последняя пятница»
+
+	Hourly   Kind = "h"   // каждые N часов от времени начала задачи
+	Minutely Kind = "min" // каждые N минут от времени начала задачи
+)
+
+// NthWeekday — n-й день недели месяца. N от 1 до 5 считается с начала месяца,
+// от -1 до -5 — с конца: {N: -1, Weekday: 5} означает последнюю пятницу.
+type NthWeekday struct {
+	N       int
+	Weekday int // 1 — понедельник, 7 — воскресенье
+}
+
+// String возвращает запись вида "2:2".
+func (nw NthWeekday) String() string {
+	return strconv.Itoa(nw.N) + ":" + strconv.Itoa(nw.Weekday)
+}
+
+// RepeatRule — разобранное правило повторения задачи.
+// Нулевое значение означает, что задача не повторяется.
+type RepeatRule struct {
+	Kind      Kind
+	Interval  int   // шаг повторения: дни для d, недели для w, годы для y, часы для h, минуты для min
+	Weekdays  []int // дни недели для w: 1 — понедельник, 7 — воскресенье
+	MonthDays []int // дни месяца для m: 1..31, -1 — последний, -2 — предпоследний
+	Months    []int // месяцы для m и mw: 1..12, пусто — каждый месяц
+
+	NthWeekdays []NthWeekday // дни для mw
+
+	Adjust Adjustment // как поступать с датами, выпавшими на выходные и праздники
+
+	Until time.Time // последняя допустимая дата повторения, нулевое значение — без ограничения
+	Count int       // общее число повторений начиная с даты задачи, 0 — без ограничения
+
+	// Except — даты-исключения, в которые повторения пропускаются. Хранятся
+	// у задачи отдельно от правила и в его строковую запись не входят.
+	Except []time.Time
+}
+
+// Adjustment — поправка даты повторения по производственному календарю.
+type Adjustment string
+
+const (
+	SkipHolidays   Adjustment = "workdays" // повторения в нерабочие дни пропускаются
+	ShiftToWorkday Adjustment = "shift"    // повторение в нерабочий день переносится на ближайший рабочий
+)
+
+// ErrNoOccurrences возвращается, когда правило с условием окончания больше не даёт дат.
+var ErrNoOccurrences = errors.New("nextDate: повторения по правилу закончились")
+
+// Parse разбирает строку правила повторения.
+// Пустая строка даёт нулевое правило без ошибки.
+// Ошибка разбора имеет тип *RuleError и указывает на ошибочное место правила.
+func Parse(repeat string) (RepeatRule, error) {
+	tokens := tokenize(repeat)
+	if len(tokens) == 0 {
+		return RepeatRule{}, nil
+	}
+
+	kind := tokens[0]
+	rule := RepeatRule{Kind: Kind(kind.text)}
+	args, mods := splitModifiers(tokens[1:])
+
+	// missing указывает место, где ожидалось недостающее значение
+	missing := kind.end()
+	if len(args) > 0 {
+		missing = args[len(args)-1].end()
+	}
+	// arity проверяет число позиционных значений правила
+	arity := func(min, max int, detail string) error {
+		if len(args) < min {
+			return ruleErr(CodeMissingValue, missing, detail)
+		}
+		if len(args) > max {
+			return ruleErr(CodeExtraValue, args[max], detail)
+		}
+		return nil
+	}
+	months := func() error {
+		if len(args) < 2 {
+			return nil
+		}
+		var err error
+		rule.Months, err = parseList(args[1], 1, 12, "месяц", nil)
+		return err
+	}
+
+	var err error
+	switch rule.Kind {
+	case Daily:
+		if err = arity(1, 1, "повторение по дням должно иметь одно дополнительное значение — количество дней"); err == nil {
+			rule.Interval, err = parseNumber(args[0], 1, 400, "количество дней")
+		}
+
+	case Weekly:
+		if err = arity(1, 2, "повторение по неделям должно иметь одно или два дополнительных значения: дни недели и интервал в неделях"); err != nil {
+			break
+		}
+		if rule.Weekdays, err = parseList(args[0], 1, 7, "день недели", nil); err != nil {
+			break
+		}
+		rule.Interval = 1
+		if len(args) == 2 {
+			rule.Interval, err = parseNumber(args[1], 1, 52, "интервал в неделях")
+		}
+
+	case Monthly:
+		if err = arity(1, 2, "повторение по месяцам должно иметь одно или два дополнительных значения: дни месяца и месяцы"); err != nil {
+			break
+		}
+		rule.MonthDays, err = parseList(args[0], -2, 31, "день месяца", func(n int) bool { return n != 0 })
+		if err == nil {
+			err = months()
+		}
+		if err == nil && !rule.feasible() {
+			err = ruleErr(CodeImpossibleDate, args[0], "ни один из указанных дней не существует в указанных месяцах")
+		}
+
+	case MonthlyByWeekday:
+		if err = arity(1, 2, "повторение по дням недели месяца должно иметь одно или два дополнительных значения: дни вида N:D и месяцы"); err != nil {
+			break
+		}
+		if rule.NthWeekdays, err = parseNthWeekdays(args[0]); err == nil {
+			err = months()
+		}
+
+	case Hourly:
+		if err = arity(1, 1, "повторение по часам должно иметь одно дополнительное значение — количество часов"); err == nil {
+			rule.Interval, err = parseNumber(args[0], 1, 24, "количество часов")
+		}
+
+	case Minutely:
+		if err = arity(1, 1, "повторение по минутам должно иметь одно дополнительное значение — количество минут"); err == nil {
+			rule.Interval, err = parseNumber(args[0], 1, 1440, "количество минут")
+		}
+
+	case Yearly:
+		if err = arity(0, 1, "годовое повторение может иметь только интервал в годах"); err != nil {
+			break
+		}
+		rule.Interval = 1
+		if len(args) == 1 {
+			rule.Interval, err = parseNumber(args[0], 1, 100, "интервал в годах")
+		}
+
+	default:
+		err = ruleErr(CodeUnknownKind, kind, "неподдерживаемый модификатор повторения, ожидается d, w, m, mw, y, h или min")
+	}
+	if err != nil {
+		return RepeatRule{}, err
+	}
+
+	if err := rule.parseModifiers(mods); err != nil {
+		return RepeatRule{}, err
+	}
+
+	return rule, nil
+}
+
+// parseNumber разбирает число из токена и проверяет, что оно лежит в диапазоне min..max.
+func parseNumber(t token, min, max int, what string) (int, error) {
+	n, err := strconv.Atoi(t.text)
+	if err != nil {
+		return 0, ruleErr(CodeNotANumber, t, what+": ожидается число")
+	}
+	if n < min || n > max {
+		return 0, rangeErr(t, min, max, fmt.Sprintf("%s: ожидается значение от %d до %d", what, min, max))
+	}
+	return n, nil
+}
+
+// parseList разбирает список чисел через запятую, проверяет каждое значение
+// и возвращает отсортированный список без повторов. Значения должны лежать
+// в диапазоне min..max; valid, если задана, дополнительно отсеивает значения внутри него.
+func parseList(t token, min, max int, what string, valid func(int) bool) ([]int, error) {
+	parts := strings.Split(t.text, ",")
+	list := make([]int, 0, len(parts))
+	offset := 0
+	for _, p := range parts {
+		item := t.sub(offset, p)
+		offset += len(p) + 1
+
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, ruleErr(CodeNotANumber, item, fmt.Sprintf("некорректный %s, ожидается число", what))
+		}
+		if n < min || n > max || valid != nil && !valid(n) {
+			return nil, rangeErr(item, min, max, fmt.Sprintf("некорректный %s", what))
+		}
+		list = append(list, n)
+	}
+	slices.Sort(list)
+	return slices.Compact(list), nil
+}
+
+// parseNthWeekdays разбирает список вида "2:2,-1:5"
+// и возвращает его отсортированным без повторов.
+func parseNthWeekdays(t token) ([]NthWeekday, error) {
+	parts := strings.Split(t.text, ",")
+	list := make([]NthWeekday, 0, len(parts))
+	offset := 0
+	for _, p := range parts {
+		item := t.sub(offset, p)
+		offset += len(p) + 1
+
+		ns, ws, ok := strings.Cut(p, ":")
+		if !ok {
+			return nil, ruleErr(CodeBadFormat, item, "некорректный день недели месяца, ожидается N:D, где N от -5 до 5 без 0, D от 1 до 7")
+		}
+		nt, wt := item.sub(0, ns), item.sub(len(ns)+1, ws)
+		n, err := strconv.Atoi(ns)
+		if err != nil {
+			return nil, ruleErr(CodeNotANumber, nt, "номер дня недели в месяце должен быть числом")
+		}
+		if n == 0 || n < -5 || n > 5 {
+			return nil, rangeErr(nt, -5, 5, "номер дня недели в месяце должен быть от -5 до 5 без 0")
+		}
+		wd, err := strconv.Atoi(ws)
+		if err != nil {
+			return nil, ruleErr(CodeNotANumber, wt, "день недели должен быть числом")
+		}
+		if wd < 1 || wd > 7 {
+			return nil, rangeErr(wt, 1, 7, "день недели должен быть между 1 и 7")
+		}
+		list = append(list, NthWeekday{N: n, Weekday: wd})
+	}
+	slices.SortFunc(list, func(a, b NthWeekday) int {
+		if a.N != b.N {
+			return a.N - b.N
+		}
+		return a.Weekday - b.Weekday
+	})
+	return slices.Compact(list), nil
+}
+
+// splitModifiers отделяет позиционные аргументы правила от модификаторов,
+// которые всегда идут в конце: "workdays", "shift", "until YYYYMMDD" и "xN".
+func splitModifiers(args []token) (positional, mods []token) {
+	for i, a := range args {
+		if a.text == "until" || isCount(a.text) || a.text == string(SkipHolidays) || a.text == string(ShiftToWorkday) {
+			return args[:i], args[i:]
+		}
+	}
+	return args, nil
+}
+
+// isCount проверяет, что токен имеет вид xN.
+func isCount(tok string) bool {
+	if len(tok) < 2 || tok[0] != 'x' {
+		return false
+	}
+	_, err := strconv.Atoi(tok[1:])
+	return err == nil
+}
+
+// parseModifiers разбирает поправку по календарю и условия окончания повторений.
+func (r *RepeatRule) parseModifiers(mods []token) error {
+	for i := 0; i < len(mods); i++ {
+		switch tok := mods[i]; {
+		case tok.text == string(SkipHolidays) || tok.text == string(ShiftToWorkday):
+			if r.Adjust != "" {
+				return ruleErr(CodeConflict, tok, "модификаторы workdays и shift нельзя указывать вместе или повторять")
+			}
+			r.Adjust = Adjustment(tok.text)
+
+		case tok.text == "until":
+			if !r.Until.IsZero() {
+				return ruleErr(CodeConflict, tok, "дата окончания указана дважды")
+			}
+			if i+1 >= len(mods) {
+				return ruleErr(CodeMissingValue, tok.end(), "после until должна быть указана дата в формате YYYYMMDD")
+			}
+			i++
+			until, err := time.Parse("20060102", mods[i].text)
+			if err != nil {
+				return ruleErr(CodeBadDate, mods[i], "некорректная дата окончания, ожидается YYYYMMDD")
+			}
+			r.Until = until
+
+		case isCount(tok.text):
+			if r.Count != 0 {
+				return ruleErr(CodeConflict, tok, "количество повторений указано дважды")
+			}
+			n, _ := strconv.Atoi(tok.text[1:])
+			if n < 1 || n > maxCount {
+				return rangeErr(tok, 1, maxCount, fmt.Sprintf("количество повторений должно быть между 1 и %d", maxCount))
+			}
+			r.Count = n
+
+		default:
+			return ruleErr(CodeExtraValue, tok, "неизвестное условие окончания, ожидается workdays, shift, until YYYYMMDD или xN")
+		}
+	}
+	return nil
+}
+
+// maxCount — наибольшее допустимое значение xN.
+const maxCount = 9999
+
+// IsZero сообщает, что правило пустое, то есть задача не повторяется.
+func (r RepeatRule) IsZero() bool {
+	return r.Kind == ""
+}
+
+// SubDaily сообщает, что правило повторяется несколько раз в день (h или min)
+// и считается от времени начала задачи, а не от её даты.
+func (r RepeatRule) SubDaily() bool {
+	return r.Kind == Hourly || r.Kind == Minutely
+}
+
+// String возвращает каноническую запись правила: Parse(r.String()) даёт то же правило.
+func (r RepeatRule) String() string {
+	s := r.base()
+	if s == "" {
+		return ""
+	}
+	if r.Adjust != "" {
+		s += " " + string(r.Adjust)
+	}
+	if !r.Until.IsZero() {
+		s += " until " + r.Until.Format("20060102")
+	}
+	if r.Count > 0 {
+		s += " x" + strconv.Itoa(r.Count)
+	}
+	return s
+}
+
+// base возвращает запись правила без условий окончания.
+func (r RepeatRule) base() string {
+	switch r.Kind {
+	case Daily, Hourly, Minutely:
+		return string(r.Kind) + " " + strconv.Itoa(r.Interval)
+	case Weekly:
+		s := "w " + joinInts(r.Weekdays)
+		if r.Interval > 1 {
+			s += " " + strconv.Itoa(r.Interval)
+		}
+		return s
+	case Monthly:
+		s := "m " + joinInts(r.MonthDays)
+		if len(r.Months) > 0 {
+			s += " " + joinInts(r.Months)
+		}
+		return s
+	case Yearly:
+		if r.Interval > 1 {
+			return "y " + strconv.Itoa(r.Interval)
+		}
+		return "y"
+	case MonthlyByWeekday:
+		parts := make([]string, len(r.NthWeekdays))
+		for i, nw := range r.NthWeekdays {
+			parts[i] = nw.String()
+		}
+		s := "mw " + strings.Join(parts, ",")
+		if len(r.Months) > 0 {
+			s += " " + joinInts(r.Months)
+		}
+		return s
+	}
+	return ""
+}
+
+// Next возвращает ближайшую дату повторения, которая строго позже и now, и start.
+// Сравниваются только календарные даты; время суток start переносится на найденную дату.
+// Для правил h и min сравнивается время по местным часам now и start, и шаг
+// отсчитывается от start по местным часам: в ночь перехода на летнее время
+// реальный промежуток между повторениями может оказаться на час длиннее или короче.
+// Поправка Adjust применяется по календарю, заданному через SetCalendar.
+// Если такая дата позже Until, возвращается ErrNoOccurrences.
+// Даты из Except пропускаются, для правил h и min — целиком.
+// Count здесь не учитывается: сколько повторений осталось, знает только хранилище.
+func (r RepeatRule) Next(now, start time.Time) (time.Time, error) {
+	for {
+		next, err := r.adjusted(now, start)
+		if err != nil || !slices.Contains(r.Except, dateOf(next)) {
+			return next, err
+		}
+		now = next
+		if r.SubDaily() {
+			now = dateOf(next).AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+}
+
+// adjusted ищет ближайшую дату повторения с учётом поправки по календарю и Until.
+func (r RepeatRule) adjusted(now, start time.Time) (time.Time, error) {
+	next, err := r.next(now, start)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	limit := next.AddDate(searchYears, 0, 0)
+	for !calendar.IsWorkday(next) && r.Adjust != "" {
+		if !r.Until.IsZero() && dateOf(next).After(r.Until) || !next.Before(limit) {
+			break
+		}
+		if r.Adjust == ShiftToWorkday {
+			// Перенос не сдвигает якорь: следующие даты считаются от даты задачи
+			next = next.AddDate(0, 0, 1)
+			continue
+		}
+		after := next
+		if r.SubDaily() {
+			// Нерабочий день пропускаем целиком, а не по одному шагу
+			after = dateOf(next).AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		if next, err = r.next(after, start); err != nil {
+			return time.Time{}, err
+		}
+	}
+	if !next.Before(limit) {
+		return time.Time{}, fmt.Errorf("nextDate: не удалось найти рабочий день для правила [%s]", r)
+	}
+
+	if !r.Until.IsZero() && dateOf(next).After(r.Until) {
+		return time.Time{}, ErrNoOccurrences
+	}
+	if r.SubDaily() {
+		return next, nil
+	}
+	return next.Add(start.Sub(dateOf(start))), nil
+}
+
+// next ищет ближайшую дату повторения без учёта условий окончания.
+func (r RepeatRule) next(now, start time.Time) (time.Time, error) {
+	if r.SubDaily() {
+		step := time.Duration(r.Interval) * time.Minute
+		if r.Kind == Hourly {
+			step = time.Duration(r.Interval) * time.Hour
+		}
+		start = wallClock(start)
+		after := wallClock(now)
+		if start.After(after) {
+			after = start
+		}
+		return start.Add((after.Sub(start)/step + 1) * step), nil
+	}
+
+	start = dateOf(start)
+	after := dateOf(now)
+	if start.After(after) {
+		after = start
+	}
+
+	switch r.Kind {
+	case Daily:
+		days := int(after.Sub(start).Hours() / 24)
+		return start.AddDate(0, 0, (days/r.Interval+1)*r.Interval), nil
+
+	case Yearly:
+		next := start.AddDate(r.Interval, 0, 0)
+		for k := 2 * r.Interval; !next.After(after); k += r.Interval {
+			next = start.AddDate(k, 0, 0)
+		}
+		return next, nil
+
+	case Weekly, Monthly, MonthlyByWeekday:
+		limit := after.AddDate(searchYears, 0, 0)
+		bounded := !r.Until.IsZero() && r.Until.Before(limit)
+		if bounded {
+			limit = r.Until.AddDate(0, 0, 1)
+		}
+		for d := after.AddDate(0, 0, 1); d.Before(limit); d = d.AddDate(0, 0, 1) {
+			if r.matches(d) && r.inPhase(start, d) {
+				return d, nil
+			}
+		}
+		if bounded {
+			return time.Time{}, ErrNoOccurrences
+		}
+		return time.Time{}, fmt.Errorf("nextDate: не удалось найти ближайшую дату для правила [%s]", r)
+	}
+
+	return time.Time{}, errors.New("nextDate: правило повторения не задано")
+}
+
+// searchYears ограничивает перебор дат для правил w, m и mw.
+// Восьми лет хватает даже для 29 февраля через вековой невисокосный год.
+const searchYears = 9
+
+// matches проверяет, подходит ли дата под календарное правило (w, m или mw).
+func (r RepeatRule) matches(d time.Time) bool {
+	switch r.Kind {
+	case MonthlyByWeekday:
+		if len(r.Months) > 0 && !slices.Contains(r.Months, int(d.Month())) {
+			return false
+		}
+		fromStart := (d.Day()-1)/7 + 1
+		fromEnd := -((daysIn(d.Year(), d.Month())-d.Day())/7 + 1)
+		for _, nw := range r.NthWeekdays {
+			if nw.Weekday == isoWeekday(d) && (nw.N == fromStart || nw.N == fromEnd) {
+				return true
+			}
+		}
+		return false
+	case Weekly:
+		return slices.Contains(r.Weekdays, isoWeekday(d))
+	case Monthly:
+		if len(r.Months) > 0 && !slices.Contains(r.Months, int(d.Month())) {
+			return false
+		}
+		last := daysIn(d.Year(), d.Month())
+		for _, md := range r.MonthDays {
+			if md == d.Day() || md < 0 && last+md+1 == d.Day() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inPhase проверяет, что для правила w с интервалом дата d попадает в одну из
+// недель, отстоящих от недели даты задачи start на кратное интервалу число недель.
+func (r RepeatRule) inPhase(start, d time.Time) bool {
+	if r.Kind != Weekly || r.Interval <= 1 {
+		return true
+	}
+	weekOf := func(t time.Time) time.Time { return t.AddDate(0, 0, 1-isoWeekday(t)) }
+	weeks := int(weekOf(d).Sub(weekOf(start)).Hours()/24) / 7
+	return weeks%r.Interval == 0
+}
+
+// feasible проверяет, что правило m описывает хотя бы одну существующую дату.
+func (r RepeatRule) feasible() bool {
+	months := r.Months
+	if len(months) == 0 {
+		months = []int{1}
+	}
+	for _, m := range months {
+		for _, md := range r.MonthDays {
+			if md < 0 || md <= daysIn(2000, time.Month(m)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// joinInts склеивает числа через запятую.
+func joinInts(list []int) string {
+	parts := make([]string, len(list))
+	for i, n := range list {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ",")
+}
+
+// dateOf отбрасывает время суток и часовой пояс, оставляя календарную дату.
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// wallClock отбрасывает часовой пояс, оставляя дату и время по местным часам.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// isoWeekday возвращает день недели в нумерации 1 — понедельник, 7 — воскресенье.
+func isoWeekday(d time.Time) int {
+	wd := int(d.Weekday())
+	if wd == 0 {
+		return 7
+	}
+	return wd
+}
+
+// daysIn возвращает количество дней в месяце.
+func daysIn(year int, m time.Month) int {
+	return time.Date(year, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,248 @@
+package nextdate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRoundTrip(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		want   string
+	}{
+		{"", ""},
+		{"d 7", "d 7"},
+		{"y", "y"},
+		{"w 3,1,5,1", "w 1,3,5"},
+		{"m 07,19 05,6", "m 7,19 5,6"},
+		{"m -1,18", "m -1,18"},
+		{"  d   10 ", "d 10"},
+		{"d 7 until 20261231", "d 7 until 20261231"},
+		{"w 3,1 x10", "w 1,3 x10"},
+		{"m 1 x3 until 20250101", "m 1 until 20250101 x3"},
+		{"mw -1:5,2:2", "mw -1:5,2:2"},
+		{"w 1 2", "w 1 2"},
+		{"w 1,5 1", "w 1,5"},
+		{"y 3", "y 3"},
+		{"y 1", "y"},
+		{"mw 2:2,1:1,2:2 6,1", "mw 1:1,2:2 1,6"},
+		{"h 3", "h 3"},
+		{"min 015 until 20261231", "min 15 until 20261231"},
+	}
+	for _, v := range tbl {
+		rule, err := Parse(v.repeat)
+		assert.NoError(t, err, v.repeat)
+		assert.Equal(t, v.want, rule.String(), v.repeat)
+
+		again, err := Parse(rule.String())
+		assert.NoError(t, err, v.repeat)
+		assert.Equal(t, rule, again, v.repeat)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, repeat := range []string{
+		"k 34", "ooops", "d", "d 0", "d 401", "d x", "y 0", "y 101", "y 1 2",
+		"w", "w 0", "w 8,4,5", "w 1 0", "w 1 53", "w 1 2 3",
+		"m", "m 0", "m 32", "m -3", "m -2,-3", "m 40,11,19", "m 1 13", "m 30,31 2",
+		"mw", "mw 2", "mw 0:1", "mw 6:1", "mw 1:8", "mw 1:1 13", "mw 1:1 1 2",
+		"d 7 until", "d 7 until 2026", "d 7 x0", "w 1 x2 x3", "y x", "d 7 forever",
+		"h", "h 0", "h 25", "h 1 2", "min", "min 0", "min 1441", "mi 5",
+	} {
+		_, err := Parse(repeat)
+		assert.Error(t, err, repeat)
+	}
+}
+
+func TestNextDateRules(t *testing.T) {
+	now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+	tbl := []struct {
+		date   string
+		repeat string
+		want   string
+	}{
+		{"16890220", "y", "20240220"},
+		{"20240229", "y", "20250301"},
+		{"20240113", "d 7", "20240127"},
+		{"20231225", "d 12", "20240130"},
+		{"20231106", "m 13", "20240213"},
+		{"20240116", "m 16,5", "20240205"},
+		{"20240126", "m 25,26,7", "20240207"},
+		{"20240409", "m 31", "20240531"},
+		{"20240329", "m 10,17 12,8,1", "20240810"},
+		{"20230311", "m 07,19 05,6", "20240507"},
+		{"20230311", "m 1 1,2", "20240201"},
+		{"20240127", "m -1", "20240131"},
+		{"20240222", "m -2", "20240228"},
+		{"20240326", "m -1,-2", "20240330"},
+		{"20240201", "m -1,18", "20240218"},
+		{"20240125", "w 1,2,3", "20240129"},
+		{"20240126", "w 7", "20240128"},
+		{"20230126", "w 4,5", "20240201"},
+		{"20240101", "mw 2:2", "20240213"},
+		{"20240101", "mw -1:3", "20240131"},
+		{"20240126", "mw -1:5", "20240223"},
+		{"20240101", "mw 5:1", "20240129"},
+		{"20240130", "mw 5:1", "20240429"},
+		{"20240101", "mw 1:1,-1:1 3", "20240304"},
+		{"20240101", "w 1 2", "20240129"},
+		{"20240108", "w 1 2", "20240205"},
+		{"20240103", "w 1,3 2", "20240129"},
+		{"20240128", "w 1,7 3", "20240212"},
+		{"20240131", "w 3 2", "20240214"},
+		{"20200115", "y 3", "20260115"},
+		{"20220215", "y 2", "20240215"},
+	}
+	for _, v := range tbl {
+		got, err := NextDate(now, v.date, v.repeat, "")
+		assert.NoError(t, err, "%s %s", v.date, v.repeat)
+		assert.Equal(t, v.want, got, "%s %s", v.date, v.repeat)
+	}
+}
+
+func TestEndConditions(t *testing.T) {
+	now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+
+	got, err := NextDate(now, "20240113", "d 7 until 20240127", "done")
+	assert.NoError(t, err)
+	assert.Equal(t, "20240127", got)
+
+	_, err = NextDate(now, "20240113", "d 7 until 20240126", "done")
+	assert.ErrorIs(t, err, ErrNoOccurrences)
+
+	_, err = NextDate(now, "20240125", "w 1 until 20240128", "done")
+	assert.ErrorIs(t, err, ErrNoOccurrences)
+
+	// Время начала переносится на следующую дату, в том числе в последний день until
+	got, err = NextDate(now, "20240113 10:30", "d 7 until 20240127", "done")
+	assert.NoError(t, err)
+	assert.Equal(t, "20240127 10:30", got)
+
+	rule, err := Parse("w 1,3 x3")
+	assert.NoError(t, err)
+	var dates []string
+	for d := range Occurrences(time.Date(2024, 1, 22, 0, 0, 0, 0, time.UTC), rule, now, 0) {
+		dates = append(dates, d.Format("20060102"))
+	}
+	// 22.01 и 24.01 — первые два повторения из трёх, они уже в прошлом
+	assert.Equal(t, []string{"20240129"}, dates)
+}
+
+func TestParseErrors(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		code   string
+		token  string
+		pos    int
+	}{
+		{"k 34", CodeUnknownKind, "k", 0},
+		{"d", CodeMissingValue, "", 1},
+		{"d 401", CodeOutOfRange, "401", 2},
+		{"d x", CodeNotANumber, "x", 2},
+		{"y 1 2", CodeExtraValue, "2", 4},
+		{"w 1,9,3", CodeOutOfRange, "9", 4},
+		{"w 1 53", CodeOutOfRange, "53", 4},
+		{"m 1,-3", CodeOutOfRange, "-3", 4},
+		{"m 30,31 2", CodeImpossibleDate, "30,31", 2},
+		{"mw 1:1,2", CodeBadFormat, "2", 7},
+		{"mw 1:1,6:1", CodeOutOfRange, "6", 7},
+		{"mw -1:8", CodeOutOfRange, "8", 6},
+		{"m  1 13", CodeOutOfRange, "13", 5},
+		{"d 7 until", CodeMissingValue, "", 9},
+		{"d 7 until 2026", CodeBadDate, "2026", 10},
+		{"w 1 x2 x3", CodeConflict, "x3", 7},
+		{"d 7 x0", CodeOutOfRange, "x0", 4},
+		{"d 7 workdays shift", CodeConflict, "shift", 13},
+		{"d 7 x2 forever", CodeExtraValue, "forever", 7},
+		{"ж 7", CodeUnknownKind, "ж", 0},
+		{"d ж", CodeNotANumber, "ж", 2},
+	}
+	for _, v := range tbl {
+		_, err := Parse(v.repeat)
+		var re *RuleError
+		if !assert.ErrorAs(t, err, &re, v.repeat) {
+			continue
+		}
+		assert.Equal(t, v.code, re.Code, v.repeat)
+		assert.Equal(t, v.token, re.Token, v.repeat)
+		assert.Equal(t, v.pos, re.Pos, v.repeat)
+	}
+
+	_, err := Parse("w 1 53")
+	fe := FieldErrorOf("repeat", err)
+	assert.Equal(t, "repeat", fe.Field)
+	if assert.NotNil(t, fe.Min) && assert.NotNil(t, fe.Max) {
+		assert.Equal(t, 1, *fe.Min)
+		assert.Equal(t, 52, *fe.Max)
+	}
+}
+
+func TestSubDailyRules(t *testing.T) {
+	now := time.Date(2024, 1, 26, 10, 30, 0, 0, time.UTC) // пятница
+	tbl := []struct {
+		date   string
+		repeat string
+		want   string
+	}{
+		{"20240126 08:00", "h 3", "20240126 11:00"},
+		{"20240126 10:30", "h 1", "20240126 11:30"},
+		{"20240125 23:50", "min 15", "20240126 10:35"},
+		{"20240126 12:00", "h 2", "20240126 14:00"},
+		{"20240126 20:00", "h 6", "20240127 02:00"},
+		// Выходные пропускаются целиком: следующее повторение — в понедельник
+		{"20240126 22:00", "h 3 workdays", "20240129 01:00"},
+		{"20240126 08:00", "h 12 until 20240126", "20240126 20:00"},
+	}
+	for _, v := range tbl {
+		got, err := NextDate(now, v.date, v.repeat, "done")
+		assert.NoError(t, err, "%s %s", v.date, v.repeat)
+		assert.Equal(t, v.want, got, "%s %s", v.date, v.repeat)
+	}
+
+	_, err := NextDate(now, "20240126 08:00", "h 24 until 20240126", "done")
+	assert.ErrorIs(t, err, ErrNoOccurrences)
+
+	// Время сравнивается по местным часам now, а не по UTC
+	moscow := time.FixedZone("MSK", 3*3600)
+	got, err := NextDate(time.Date(2024, 1, 26, 10, 30, 0, 0, moscow), "20240126 08:00", "h 1", "done")
+	assert.NoError(t, err)
+	assert.Equal(t, "20240126 11:00", got)
+}
+
+func TestExceptDates(t *testing.T) {
+	now := time.Date(2024, 1, 26, 10, 30, 0, 0, time.UTC) // пятница
+	tbl := []struct {
+		date   string
+		repeat string
+		except []string
+		want   string
+	}{
+		{"20240126", "d 1", []string{"20240127", "20240128"}, "20240129"},
+		{"20240126", "w 5", []string{"20240202"}, "20240209"},
+		{"20240126", "d 1", []string{"20240126"}, "20240127"},
+		// Для правил h и min пропускается весь день
+		{"20240126 08:00", "h 6", []string{"20240126"}, "20240127 02:00"},
+	}
+	for _, v := range tbl {
+		got, err := NextDate(now, v.date, v.repeat, "done", v.except...)
+		assert.NoError(t, err, "%s %s %v", v.date, v.repeat, v.except)
+		assert.Equal(t, v.want, got, "%s %s %v", v.date, v.repeat, v.except)
+	}
+
+	_, err := NextDate(now, "20240126", "d 1 until 20240127", "done", "20240127")
+	assert.ErrorIs(t, err, ErrNoOccurrences)
+	_, err = NextDate(now, "20240126", "d 1", "done", "2024-01-27")
+	assert.Error(t, err)
+
+	// В Count исключённые даты засчитываются, но не выдаются
+	rule, err := Parse("d 1 x4")
+	assert.NoError(t, err)
+	rule.Except, _ = ParseDates([]string{"20240126", "20240128"})
+	var got []string
+	for d := range Occurrences(time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC), rule, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 0) {
+		got = append(got, d.Format("20060102"))
+	}
+	assert.Equal(t, []string{"20240127", "20240129"}, got)
+}
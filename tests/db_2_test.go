@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 )
@@ -16,6 +17,19 @@ type Task struct {
 	Title   string `db:"title"`
 	Comment string `db:"comment"`
 	Repeat  string `db:"repeat"`
+
+	Remaining int    `db:"remaining"`
+	Time      string `db:"time"`
+	Duration  int    `db:"duration"`
+	TZ        string `db:"tz"`
+	ExDates   string `db:"exdates"`
+	DeletedAt string `db:"deleted_at"`
+	Version   int64  `db:"version"`
+	NextDate  string `db:"next_date"`
+	RefreshAt string `db:"refresh_at"`
+
+	TitleLower   string `db:"title_lower"`
+	CommentLower string `db:"comment_lower"`
 }
 
 func count(db *sqlx.DB) (int, error) {
@@ -23,7 +37,15 @@ func count(db *sqlx.DB) (int, error) {
 	return count, db.Get(&count, `SELECT count(id) FROM scheduler`)
 }
 
+// openDB подключается к той же базе, что и сервер: к PostgreSQL, если TODO_DB_DRIVER=postgres
+// (DSN берётся из TODO_DB_DSN), иначе к файлу SQLite
 func openDB(t *testing.T) *sqlx.DB {
+	if os.Getenv("TODO_DB_DRIVER") == "postgres" {
+		db, err := sqlx.Connect("postgres", os.Getenv("TODO_DB_DSN"))
+		assert.NoError(t, err)
+		return db
+	}
+
 	dbfile := DBFile
 	envFile := os.Getenv("TODO_DBFILE")
 	if len(envFile) > 0 {
@@ -34,6 +56,20 @@ func openDB(t *testing.T) *sqlx.DB {
 	return db
 }
 
+// insert добавляет строку и возвращает её ID: PostgreSQL не поддерживает LastInsertId
+func insert(db *sqlx.DB, query string, args ...any) (int64, error) {
+	if db.DriverName() == "postgres" {
+		var id int64
+		err := db.QueryRow(db.Rebind(query+" RETURNING id"), args...).Scan(&id)
+		return id, err
+	}
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
 func TestDB(t *testing.T) {
 	db := openDB(t)
 	defer db.Close()
@@ -43,20 +79,18 @@ func TestDB(t *testing.T) {
 
 	today := time.Now().Format(`20060102`)
 
-	res, err := db.Exec(`INSERT INTO scheduler (date, title, comment, repeat) 
+	id, err := insert(db, `INSERT INTO scheduler (date, title, comment, repeat) 
 	VALUES (?, 'Todo', 'Комментарий', '')`, today)
 	assert.NoError(t, err)
 
-	id, err := res.LastInsertId()
-
 	var task Task
-	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	err = db.Get(&task, db.Rebind(`SELECT * FROM scheduler WHERE id=?`), id)
 	assert.NoError(t, err)
 	assert.Equal(t, id, task.ID)
 	assert.Equal(t, `Todo`, task.Title)
 	assert.Equal(t, `Комментарий`, task.Comment)
 
-	_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+	_, err = db.Exec(db.Rebind(`DELETE FROM scheduler WHERE id = ?`), id)
 	assert.NoError(t, err)
 
 	after, err := count(db)